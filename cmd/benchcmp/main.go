@@ -0,0 +1,249 @@
+// Copyright 2023 Xavier MARCELET. All rights reserved.
+// Use of this source code is governed by Apache
+// license that can be found in the LICENSE file.
+
+// Command benchcmp compares two `go test -bench` outputs and fails when a
+// benchmark got slower than given threshold.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// procsSuffix - GOMAXPROCS suffix appended to benchmark names by go test,
+// omitted when GOMAXPROCS is 1
+var procsSuffix = regexp.MustCompile(`-[0-9]+$`)
+
+type App struct {
+	help      bool
+	threshold float64
+	metrics   string
+}
+
+// results - measures of each benchmark by unit, one per run
+type results map[string]map[string][]float64
+
+func NewApp() *App {
+	return &App{}
+}
+
+func (a *App) printUsage() {
+	stream := flag.CommandLine.Output()
+	fmt.Fprintf(stream, "usage: %s [options] old.txt new.txt\n", os.Args[0])
+	fmt.Fprintln(stream)
+	fmt.Fprintf(stream, "Compare benchmarks of two 'go test -bench' outputs, median of runs given by -count is compared.\n")
+	fmt.Fprintf(stream, "Exits with an error when a metric of any benchmark regressed by more than threshold.\n")
+	fmt.Fprintln(stream)
+	flag.PrintDefaults()
+}
+
+func (a *App) parseArgs() {
+	flag.BoolVar(&a.help, "help", false, "print usage")
+	flag.Float64Var(&a.threshold, "threshold", 10, "tolerated regression, in percent")
+	flag.StringVar(&a.metrics, "metrics", "ns/op,allocs/op", "comma separated units to compare, among 'ns/op', 'B/op', 'allocs/op', 'MB/s'\n"+
+		"or any custom metric, higher is better for units per second")
+	flag.Usage = a.printUsage
+	flag.Parse()
+	if a.help {
+		a.printUsage()
+		os.Exit(0)
+	}
+}
+
+func (a *App) run() error {
+	a.parseArgs()
+	if flag.NArg() != 2 {
+		a.printUsage()
+		return fmt.Errorf("invalid arguments, expecting old and new result files")
+	}
+	if a.threshold < 0 {
+		return fmt.Errorf("invalid threshold '%v', expecting positive percentage", a.threshold)
+	}
+
+	before, err := parseFile(flag.Arg(0))
+	if err != nil {
+		return err
+	}
+	after, err := parseFile(flag.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	regressions := a.compare(os.Stdout, before, after)
+	if regressions != 0 {
+		return fmt.Errorf("%d benchmark metrics regressed by more than %v%%", regressions, a.threshold)
+	}
+	return nil
+}
+
+// compare writes a table of medians before and after of every benchmark found
+// in both results, returns number of regressions beyond threshold
+//
+//  1. units per second are better when higher, other units when lower
+//  2. any increase from zero, allocations for instance, is an infinite
+//     regression
+func (a *App) compare(stream io.Writer, before, after results) int {
+	var (
+		writer = tabwriter.NewWriter(stream, 0, 0, 2, ' ', 0)
+		names  = []string{}
+		count  = 0
+	)
+	for cName := range after {
+		if _, ok := before[cName]; ok {
+			names = append(names, cName)
+		}
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(writer, "name\tunit\told\tnew\tdelta\t")
+	for _, cName := range names {
+		for _, cUnit := range strings.Split(a.metrics, ",") {
+			beforeValues, afterValues := before[cName][cUnit], after[cName][cUnit]
+			if len(beforeValues) == 0 || len(afterValues) == 0 {
+				continue
+			}
+			beforeValue, afterValue := median(beforeValues), median(afterValues)
+			delta := (afterValue - beforeValue) * 100 / beforeValue
+			if beforeValue == afterValue {
+				delta = 0
+			}
+			// 1.
+			worse := delta
+			if strings.HasSuffix(cUnit, "/s") {
+				worse = -delta
+			}
+			status := ""
+			// 2.
+			if worse > a.threshold {
+				status = "REGRESSION"
+				count++
+			}
+			fmt.Fprintf(writer, "%s\t%s\t%.10g\t%.10g\t%+.2f%%\t%s\n", cName, cUnit, beforeValue, afterValue, delta, status)
+		}
+	}
+	for _, cName := range sortedNames(before) {
+		if _, ok := after[cName]; !ok {
+			fmt.Fprintf(writer, "%s\t\t\t\tremoved\t\n", cName)
+		}
+	}
+	for _, cName := range sortedNames(after) {
+		if _, ok := before[cName]; !ok {
+			fmt.Fprintf(writer, "%s\t\t\t\tadded\t\n", cName)
+		}
+	}
+	writer.Flush()
+	return count
+}
+
+// sortedNames gives benchmark names of given results in alphabetical order
+func sortedNames(values results) []string {
+	names := make([]string, 0, len(values))
+	for cName := range values {
+		names = append(names, cName)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// median gives middle value of given runs, mean of both middle ones for an
+// even count
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
+
+func parseFile(path string) (results, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read result file '%s': %s", path, err)
+	}
+	defer file.Close()
+
+	values, err := parse(file)
+	if err != nil {
+		return nil, fmt.Errorf("invalid result file '%s': %s", path, err)
+	}
+	return values, nil
+}
+
+// parse reads benchmark lines of go test output, other lines are ignored
+//
+// A benchmark line holds name, number of iterations then pairs of value and
+// unit: 'BenchmarkRotate/90/720p-8   2456   487008 ns/op   246040 B/op'.
+//
+//  1. GOMAXPROCS suffix is removed so that runs of different machines match,
+//     only when all benchmarks share the same one, otherwise it may be part
+//     of names
+func parse(stream io.Reader) (results, error) {
+	var (
+		lines    = [][]string{}
+		suffix   = ""
+		scanner  = bufio.NewScanner(stream)
+		suffixed = true
+	)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") || len(fields)%2 != 0 {
+			continue
+		}
+		if _, err := strconv.Atoi(fields[1]); err != nil {
+			continue
+		}
+		for cIdx := 2; cIdx < len(fields); cIdx += 2 {
+			if _, err := strconv.ParseFloat(fields[cIdx], 64); err != nil {
+				return nil, fmt.Errorf("invalid value '%s' on line %d, expecting decimal number", fields[cIdx], line)
+			}
+		}
+		current := procsSuffix.FindString(fields[0])
+		if len(lines) == 0 {
+			suffix = current
+		}
+		suffixed = suffixed && current != "" && current == suffix
+		lines = append(lines, fields)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("no benchmark found")
+	}
+
+	values := results{}
+	for _, cFields := range lines {
+		name := cFields[0]
+		// 1.
+		if suffixed {
+			name = strings.TrimSuffix(name, suffix)
+		}
+		if values[name] == nil {
+			values[name] = map[string][]float64{}
+		}
+		for cIdx := 2; cIdx < len(cFields); cIdx += 2 {
+			value, _ := strconv.ParseFloat(cFields[cIdx], 64)
+			values[name][cFields[cIdx+1]] = append(values[name][cFields[cIdx+1]], value)
+		}
+	}
+	return values, nil
+}
+
+func main() {
+	app := NewApp()
+	if err := app.run(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
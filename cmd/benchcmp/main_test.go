@@ -0,0 +1,125 @@
+// Copyright 2023 Xavier MARCELET. All rights reserved.
+// Use of this source code is governed by Apache
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	input := strings.Join([]string{
+		"goos: linux",
+		"BenchmarkRotate/90/720p-8   100   487008 ns/op   246040 B/op   8 allocs/op",
+		"BenchmarkRotate/90/720p-8   120   487000 ns/op   246040 B/op   8 allocs/op",
+		"BenchmarkParse/ascii-8      10    7053622 ns/op  130.76 MB/s",
+		// odd number of fields, not a result line
+		"BenchmarkParse/ascii-8      10    7053622 ns/op  130.76",
+		// iterations are not a number, not a result line
+		"BenchmarkParse/ascii-8      --- FAIL",
+		"PASS",
+	}, "\n")
+	values, err := parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(values) != 2 {
+		t.Fatalf("unexpected benchmarks %v", values)
+	}
+	if runs := values["BenchmarkRotate/90/720p"]["ns/op"]; len(runs) != 2 || runs[0] != 487008 || runs[1] != 487000 {
+		t.Fatalf("unexpected ns/op runs %v", runs)
+	}
+	if runs := values["BenchmarkParse/ascii"]["MB/s"]; len(runs) != 1 || runs[0] != 130.76 {
+		t.Fatalf("unexpected MB/s runs %v", runs)
+	}
+
+	for _, cInput := range []string{"", "PASS\n", "BenchmarkX-8 10 abc ns/op\n"} {
+		if _, err := parse(strings.NewReader(cInput)); err == nil {
+			t.Fatalf("expected error for input %q", cInput)
+		}
+	}
+}
+
+func TestParse_suffix(t *testing.T) {
+	// without GOMAXPROCS suffix, trailing numbers are part of names
+	values, err := parse(strings.NewReader("BenchmarkFoo/size-1024 10 5 ns/op\nBenchmarkFoo/size-2048 10 6 ns/op\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, ok := values["BenchmarkFoo/size-1024"]; !ok || len(values) != 2 {
+		t.Fatalf("unexpected benchmarks %v", values)
+	}
+
+	values, err = parse(strings.NewReader("BenchmarkFoo/size-1024-4 10 5 ns/op\nBenchmarkBar-4 10 6 ns/op\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, ok := values["BenchmarkFoo/size-1024"]; !ok || len(values) != 2 {
+		t.Fatalf("unexpected benchmarks %v", values)
+	}
+}
+
+func TestMedian(t *testing.T) {
+	if value := median([]float64{5, 1, 3}); value != 3 {
+		t.Fatalf("unexpected median %v of odd count", value)
+	}
+	if value := median([]float64{8, 1, 2, 4}); value != 3 {
+		t.Fatalf("unexpected median %v of even count", value)
+	}
+	if value := median([]float64{7}); value != 7 {
+		t.Fatalf("unexpected median %v of single run", value)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		unit   string
+		before float64
+		after  float64
+		count  int
+	}{
+		{"ns/op", 200, 220, 0},         // at threshold
+		{"ns/op", 200, 221, 1},         // beyond threshold
+		{"ns/op", 200, 100, 0},         // faster
+		{"MB/s", 200, 180, 0},          // at threshold, higher is better
+		{"MB/s", 200, 179, 1},          // beyond threshold
+		{"MB/s", 200, 400, 0},          // faster
+		{"allocs/op", 0, 0, 0},         // no allocation
+		{"allocs/op", 0, 1, 1},         // allocation from zero
+		{"MB/s", 0, 1, 0},              // throughput from zero
+		{"ns/op", 200, math.Inf(1), 1}, // infinitely slower
+	}
+	for _, cTest := range tests {
+		app := &App{threshold: 10, metrics: cTest.unit}
+		before := results{"BenchmarkX": {cTest.unit: {cTest.before}}}
+		after := results{"BenchmarkX": {cTest.unit: {cTest.after}}}
+		if count := app.compare(io.Discard, before, after); count != cTest.count {
+			t.Fatalf("unexpected %d regressions from %v to %v %s", count, cTest.before, cTest.after, cTest.unit)
+		}
+	}
+}
+
+func TestCompare_missing(t *testing.T) {
+	app := &App{threshold: 10, metrics: "ns/op,allocs/op"}
+	before := results{
+		"BenchmarkA": {"ns/op": {100}},
+		"BenchmarkB": {"ns/op": {100}},
+	}
+	after := results{
+		"BenchmarkA": {"ns/op": {500}, "allocs/op": {3}},
+		"BenchmarkC": {"ns/op": {500}},
+	}
+	writer := strings.Builder{}
+	if count := app.compare(&writer, before, after); count != 1 {
+		t.Fatalf("unexpected %d regressions, only ns/op of BenchmarkA is comparable", count)
+	}
+	for _, cWant := range []string{"REGRESSION", "BenchmarkB", "removed", "BenchmarkC", "added"} {
+		if !strings.Contains(writer.String(), cWant) {
+			t.Fatalf("missing '%s' in output:\n%s", cWant, writer.String())
+		}
+	}
+}
@@ -5,11 +5,21 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"flag"
 	"fmt"
+	img "image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
 	"os"
+	"path/filepath"
 	"runtime"
 	"runtime/pprof"
+	"strconv"
+	"strings"
 
 	"gihub.com/psycofdj/i-luv-grandma/pbm"
 )
@@ -22,6 +32,9 @@ var (
 	BuildDate = "unknown"
 )
 
+// pngSignature is the header of any PNG file
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
 type App struct {
 	help           bool
 	version        bool
@@ -29,6 +42,39 @@ type App struct {
 	inputFilePath  string
 	outputFilePath string
 	rotationAngle  float64
+	outputFormat   string
+	resize         bool
+	forward        bool
+	algorithm      string
+	center         string
+	deskew         bool
+	orientation    int
+	flipHorizontal bool
+	flipVertical   bool
+	transpose      bool
+	transverse     bool
+	scale          string
+	filter         string
+	command        string
+	warpFrom       string
+	warpTo         string
+	crop           string
+	pad            string
+	canvas         string
+	anchor         string
+	fill           string
+	workers        int
+	memory         string
+}
+
+// framing - crop, pad and canvas settings, applied in this order
+type framing struct {
+	crop   *img.Rectangle
+	pad    []int
+	canvas *img.Point
+	anchor pbm.Anchor
+	fill   color.Color
+	opts   []pbm.Option // number of workers
 }
 
 func NewApp() *App {
@@ -37,9 +83,22 @@ func NewApp() *App {
 
 func (a *App) printUsage() {
 	stream := flag.CommandLine.Output()
+	if a.command == "warp" {
+		fmt.Fprintf(stream, "usage: %s warp -from corners [options]\n", os.Args[0])
+		fmt.Fprintln(stream)
+		fmt.Fprintf(stream, "Correct perspective of pbm, pgm, ppm, pam or png image, mapping 'from' corners to 'to' corners.\n")
+		fmt.Fprintf(stream, "Corners are given as 'x1,y1,x2,y2,x3,y3,x4,y4' pixel coordinates, typically top-left,\n")
+		fmt.Fprintf(stream, "top-right, bottom-right and bottom-left corners of a photographed document.\n")
+		fmt.Fprintln(stream)
+		flag.PrintDefaults()
+		return
+	}
 	fmt.Fprintf(stream, "usage: %s [options]\n", os.Args[0])
+	fmt.Fprintf(stream, "       %s warp -from corners [options]\n", os.Args[0])
 	fmt.Fprintln(stream)
-	fmt.Fprintf(stream, "Rotate pbm image by given angle. Result is written to output file.\n")
+	fmt.Fprintf(stream, "Rotate pbm, pgm, ppm, pam or png image by given angle. Result is written to output file.\n")
+	fmt.Fprintf(stream, "Every image of a multi-image input is rotated and written in the same order.\n")
+	fmt.Fprintf(stream, "Png input is converted to black & white.\n")
 	fmt.Fprintln(stream)
 	flag.PrintDefaults()
 }
@@ -52,14 +111,55 @@ func (a *App) printVersion() {
 	fmt.Printf("platform: %s/%s\n", runtime.GOOS, runtime.GOARCH)
 }
 
+// parseArgs reads command line flags, first argument may select warp
+// subcommand which accepts its own flags on top of common ones
 func (a *App) parseArgs() {
+	args := os.Args[1:]
+	if len(args) != 0 && args[0] == "warp" {
+		a.command = "warp"
+		args = args[1:]
+		flag.StringVar(&a.warpFrom, "from", "", "corners of source area, 'x1,y1,x2,y2,x3,y3,x4,y4' pixel coordinates")
+		flag.StringVar(&a.warpTo, "to", "", "destination of given corners, same format as -from,\n"+
+			"corners of image (top-left, top-right, bottom-right, bottom-left) when empty")
+	}
+
 	flag.BoolVar(&a.help, "help", false, "print usage")
 	flag.BoolVar(&a.version, "version", false, "outputs version and revision informations")
 	flag.StringVar(&a.profilePath, "profile", "", "generate pprof profile output")
+	flag.IntVar(&a.workers, "workers", 0, "number of concurrent workers processing each image, GOMAXPROCS when 0")
+	flag.StringVar(&a.memory, "memory", "", "process raw (P4) input file by strips within given memory budget, bytes with optional 'K', 'M' or 'G' suffix,\n"+
+		"output is raw, only first image, orientation and rotation flags are supported")
 	flag.StringVar(&a.inputFilePath, "input", "input.pbm", "process given input file path, '-' for stdin")
 	flag.StringVar(&a.outputFilePath, "output", "output.pbm", "write to given output file path, '-' for stdout")
-	flag.Float64Var(&a.rotationAngle, "angle", 90, "rotation of given decimal angle (positive or negative)")
-	flag.Parse()
+	if a.command == "" {
+		flag.Float64Var(&a.rotationAngle, "angle", 90, "rotation of given decimal angle (positive or negative)")
+		flag.BoolVar(&a.deskew, "deskew", false, "detect skew angle of each image and rotate it straight, -angle is ignored")
+		flag.IntVar(&a.orientation, "orientation", 0, "apply exif orientation value, from 1 to 8, before rotation")
+		flag.BoolVar(&a.flipHorizontal, "flip-horizontal", false, "mirror image left to right before rotation")
+		flag.BoolVar(&a.flipVertical, "flip-vertical", false, "mirror image top to bottom before rotation")
+		flag.BoolVar(&a.transpose, "transpose", false, "mirror image along its top-left to bottom-right diagonal before rotation")
+		flag.BoolVar(&a.transverse, "transverse", false, "mirror image along its top-right to bottom-left diagonal before rotation")
+	}
+	flag.StringVar(&a.outputFormat, "format", "", "output file format, 'ascii' (plain P1/P2/P3), 'binary' (raw P4/P5/P6), 'pam' (P7) or 'png',\n"+
+		"guessed from output file extension when empty, 'png' for '.png' and 'ascii' otherwise")
+	flag.BoolVar(&a.resize, "resize", false, "grow output image so that no transformed pixel is lost")
+	if a.command == "" {
+		flag.StringVar(&a.algorithm, "algorithm", "rotator", "rotation method for angles that are not multiple of 90 degrees,\n"+
+			"'rotator' (rotation matrix) or 'shear' (three shears, preserves every pixel)")
+		flag.StringVar(&a.center, "center", "", "center of rotation, 'x,y' pixel coordinates, 'centroid' of black pixels or anchor name among\n"+
+			"'top-left', 'top', 'top-right', 'left', 'center', 'right', 'bottom-left', 'bottom', 'bottom-right',\n"+
+			"geometric center of image when empty, output keeps input size unless -resize is given")
+	}
+	flag.StringVar(&a.scale, "scale", "", "scale output image by given decimal factor or to given 'WxH' dimensions")
+	flag.StringVar(&a.filter, "filter", "box", "scaling filter, 'nearest' (neighbour), 'box' (area coverage) or 'epx' (Scale2x/EPX enlargement)")
+	flag.StringVar(&a.crop, "crop", "", "keep given 'x,y,WxH' area of output image")
+	flag.StringVar(&a.pad, "pad", "", "add 'top,right,bottom,left' margins to output image, negative margins remove pixels")
+	flag.StringVar(&a.canvas, "canvas", "", "place output image on a 'WxH' canvas, cropping it when larger")
+	flag.StringVar(&a.anchor, "anchor", "center", "position of image on canvas, anchor name as accepted by -center")
+	flag.StringVar(&a.fill, "fill", "white", "color of -pad margins and -canvas area, 'white', 'black', 'transparent' or '#rrggbb[aa]'")
+	flag.BoolVar(&a.forward, "forward", false, "project source pixels to destination instead of sampling source (may leave holes)")
+	// errors are reported by flag package before exiting
+	_ = flag.CommandLine.Parse(args)
 	if a.help {
 		a.printUsage()
 		os.Exit(0)
@@ -83,19 +183,621 @@ func (a *App) run() error {
 		defer pprof.StopCPUProfile()
 	}
 
-	image, err := pbm.NewImageFromFile(a.inputFilePath)
+	if a.workers < 0 {
+		return fmt.Errorf("invalid workers '%d', expecting positive number", a.workers)
+	}
+
+	format, err := a.format()
+	if err != nil {
+		return err
+	}
+
+	opts, err := a.options()
+	if err != nil {
+		return err
+	}
+
+	if a.memory != "" {
+		return a.processStrips(format, opts)
+	}
+
+	framing, err := a.framing()
+	if err != nil {
+		return err
+	}
+
+	input, err := a.openInput()
+	if err != nil {
+		return fmt.Errorf("could not read input file '%s': %s", a.inputFilePath, err)
+	}
+	defer input.Close()
+
+	output, err := a.openOutput()
+	if err != nil {
+		return fmt.Errorf("could not write output file '%s': %s", a.outputFilePath, err)
+	}
+	defer output.Close()
+
+	if err := a.process(a.decoder(input), a.encoder(output, format), opts, framing); err != nil {
+		return err
+	}
+	// input may be replaced by output
+	input.Close()
+	if err := output.Commit(); err != nil {
+		return fmt.Errorf("could not write output file '%s': %s", a.outputFilePath, err)
+	}
+
+	return nil
+}
+
+// process transforms, scales then frames every image of input stream,
+// writing them in same order
+func (a *App) process(next func() (pbm.Netpbm, error), write func(pbm.Netpbm) error, opts []pbm.Option, framing *framing) error {
+	for {
+		image, err := next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := a.transform(image, opts); err != nil {
+			return err
+		}
+		if err := a.resample(image); err != nil {
+			return err
+		}
+		image = framing.apply(image)
+		if err := write(image); err != nil {
+			return fmt.Errorf("could not write output file '%s': %s", a.outputFilePath, err)
+		}
+	}
+}
+
+// transform applies rotation, or perspective correction of warp subcommand,
+// to given image
+func (a *App) transform(image pbm.Netpbm, opts []pbm.Option) error {
+	if a.command != "warp" {
+		if err := a.orient(image, opts); err != nil {
+			return err
+		}
+		image.Rotate(a.angle(image), opts...)
+		return nil
+	}
+
+	from, err := parseCorners("from", a.warpFrom)
+	if err != nil {
+		return err
+	}
+	to := [4]img.Point{
+		{0, 0},
+		{image.Width() - 1, 0},
+		{image.Width() - 1, image.Height() - 1},
+		{0, image.Height() - 1},
+	}
+	if a.warpTo != "" {
+		if to, err = parseCorners("to", a.warpTo); err != nil {
+			return err
+		}
+	}
+	return image.Warp(from, to, opts...)
+}
+
+// orient applies lossless orientation flags to given image
+func (a *App) orient(image pbm.Netpbm, opts []pbm.Option) error {
+	for _, cOrientation := range a.orientations() {
+		if err := image.Orient(cOrientation, opts...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// orientations converts lossless orientation flags to orientations, exif
+// orientation first, then flips and transpositions
+func (a *App) orientations() []pbm.Orientation {
+	result := []pbm.Orientation{}
+	if a.orientation != 0 {
+		result = append(result, pbm.Orientation(a.orientation))
+	}
+	if a.flipHorizontal {
+		result = append(result, pbm.OrientationFlipHorizontal)
+	}
+	if a.flipVertical {
+		result = append(result, pbm.OrientationFlipVertical)
+	}
+	if a.transpose {
+		result = append(result, pbm.OrientationTranspose)
+	}
+	if a.transverse {
+		result = append(result, pbm.OrientationTransverse)
+	}
+	return result
+}
+
+// processStrips orients and rotates first image of raw input file, strip by
+// strip within memory budget, see pbm.Strips
+//
+// Input is read at random positions, it must be a regular file. Flags which
+// need whole image and formats other than raw are rejected.
+//
+//  1. output is raw unless format is given or guessed from extension
+func (a *App) processStrips(format string, opts []pbm.Option) error {
+	budget, err := parseBudget(a.memory)
+	if err != nil {
+		return err
+	}
+	// 1.
+	if a.outputFormat == "" && format == "ascii" {
+		format = "binary"
+	}
+	switch {
+	case a.command != "":
+		return fmt.Errorf("invalid memory budget, %s subcommand needs whole image", a.command)
+	case a.deskew || a.scale != "" || a.crop != "" || a.pad != "" || a.canvas != "":
+		return fmt.Errorf("invalid memory budget, -deskew, -scale, -crop, -pad and -canvas need whole image")
+	case format != "binary":
+		return fmt.Errorf("invalid format '%s', expecting 'binary' with memory budget", format)
+	case a.inputFilePath == "-":
+		return fmt.Errorf("invalid input, standard input can't be read by strips")
+	}
+
+	input, err := os.Open(a.inputFilePath)
+	if err != nil {
+		return fmt.Errorf("could not read input file '%s': %s", a.inputFilePath, err)
+	}
+	defer input.Close()
+
+	strips, err := pbm.NewStrips(input)
 	if err != nil {
 		return err
 	}
+	for _, cOrientation := range a.orientations() {
+		if err := strips.Orient(cOrientation); err != nil {
+			return err
+		}
+	}
+	if err := strips.Rotate(a.rotationAngle, opts...); err != nil {
+		return err
+	}
 
-	image.Rotate(a.rotationAngle)
-	if err := image.EncodeASCIIToFile(a.outputFilePath); err != nil {
+	output, err := a.openOutput()
+	if err != nil {
+		return fmt.Errorf("could not write output file '%s': %s", a.outputFilePath, err)
+	}
+	defer output.Close()
+
+	writer := bufio.NewWriter(output)
+	if err := strips.Encode(writer, budget); err != nil {
+		return err
+	}
+	if err := writer.Flush(); err != nil {
 		return fmt.Errorf("could not write output file '%s': %s", a.outputFilePath, err)
 	}
+	// input may be replaced by output
+	input.Close()
+	if err := output.Commit(); err != nil {
+		return fmt.Errorf("could not write output file '%s': %s", a.outputFilePath, err)
+	}
+	return nil
+}
+
+// parseBudget converts memory flag to a number of bytes
+func parseBudget(value string) (int, error) {
+	multiplier := 1
+	switch {
+	case strings.HasSuffix(value, "K"):
+		multiplier = 1 << 10
+	case strings.HasSuffix(value, "M"):
+		multiplier = 1 << 20
+	case strings.HasSuffix(value, "G"):
+		multiplier = 1 << 30
+	}
+	digits := value
+	if multiplier != 1 {
+		digits = value[:len(value)-1]
+	}
+	count, err := strconv.Atoi(digits)
+	if err != nil || count <= 0 {
+		return 0, fmt.Errorf("invalid memory budget '%s', expecting positive number of bytes with optional 'K', 'M' or 'G' suffix", value)
+	}
+	return count * multiplier, nil
+}
+
+// angle gives rotation angle of given image, opposite of its detected skew in
+// deskew mode
+//
+// Skew of gray and color images is detected on their black & white version.
+func (a *App) angle(image pbm.Netpbm) float64 {
+	if !a.deskew {
+		return a.rotationAngle
+	}
+	bitmap, ok := image.(*pbm.Image)
+	if !ok {
+		bitmap = pbm.NewImageFromImage(image)
+	}
+	skew, _ := bitmap.DetectSkew()
+	return -skew
+}
+
+// resample scales given image according to scale flag
+func (a *App) resample(image pbm.Netpbm) error {
+	if a.scale == "" {
+		return nil
+	}
+	filter, err := pbm.ParseFilter(a.filter)
+	if err != nil {
+		return err
+	}
+
+	invalid := fmt.Errorf("invalid scale '%s', expecting decimal factor or 'WxH' dimensions", a.scale)
+	if first, second, found := strings.Cut(a.scale, "x"); found {
+		width, errWidth := strconv.Atoi(first)
+		height, errHeight := strconv.Atoi(second)
+		if errWidth != nil || errHeight != nil {
+			return invalid
+		}
+		return image.Scale(width, height, filter, pbm.WithWorkers(a.workers))
+	}
 
+	factor, err := strconv.ParseFloat(a.scale, 64)
+	if err != nil || factor <= 0 {
+		return invalid
+	}
+	width := int(math.Round(float64(image.Width()) * factor))
+	height := int(math.Round(float64(image.Height()) * factor))
+	return image.Scale(width, height, filter, pbm.WithWorkers(a.workers))
+}
+
+// framer - images which crop, pad and canvas operations return new images of
+// the same type
+type framer[T any] interface {
+	Crop(rect img.Rectangle, opts ...pbm.Option) T
+	Pad(top, right, bottom, left int, fill color.Color, opts ...pbm.Option) T
+	Canvas(width, height int, anchor pbm.Anchor, fill color.Color, opts ...pbm.Option) T
+}
+
+// frame applies framing settings to given typed image
+func frame[T framer[T]](image T, f *framing) T {
+	if f.crop != nil {
+		image = image.Crop(*f.crop, f.opts...)
+	}
+	if f.pad != nil {
+		image = image.Pad(f.pad[0], f.pad[1], f.pad[2], f.pad[3], f.fill, f.opts...)
+	}
+	if f.canvas != nil {
+		image = image.Canvas(f.canvas.X, f.canvas.Y, f.anchor, f.fill, f.opts...)
+	}
+	return image
+}
+
+// apply returns given image cropped, padded and placed on canvas
+func (f *framing) apply(image pbm.Netpbm) pbm.Netpbm {
+	switch typed := image.(type) {
+	case *pbm.Image:
+		return frame(typed, f)
+	case *pbm.GrayImage:
+		return frame(typed, f)
+	case *pbm.ColorImage:
+		return frame(typed, f)
+	}
+	return image
+}
+
+// framing converts crop, pad, canvas, anchor and fill flags to settings
+func (a *App) framing() (*framing, error) {
+	var err error
+	result := &framing{opts: []pbm.Option{pbm.WithWorkers(a.workers)}}
+	if a.crop != "" {
+		invalid := fmt.Errorf("invalid crop '%s', expecting 'x,y,WxH'", a.crop)
+		values := strings.Split(a.crop, ",")
+		if len(values) != 3 {
+			return nil, invalid
+		}
+		x, errX := strconv.Atoi(values[0])
+		y, errY := strconv.Atoi(values[1])
+		dimensions, err := parseDimensions(values[2])
+		if errX != nil || errY != nil || err != nil {
+			return nil, invalid
+		}
+		rect := img.Rect(x, y, x+dimensions.X, y+dimensions.Y)
+		result.crop = &rect
+	}
+	if a.pad != "" {
+		values := strings.Split(a.pad, ",")
+		if len(values) != 4 {
+			return nil, fmt.Errorf("invalid pad '%s', expecting 'top,right,bottom,left'", a.pad)
+		}
+		result.pad = make([]int, len(values))
+		for cIdx, cValue := range values {
+			if result.pad[cIdx], err = strconv.Atoi(cValue); err != nil {
+				return nil, fmt.Errorf("invalid pad '%s', expecting 'top,right,bottom,left'", a.pad)
+			}
+		}
+	}
+	if a.canvas != "" {
+		dimensions, err := parseDimensions(a.canvas)
+		if err != nil {
+			return nil, fmt.Errorf("invalid canvas '%s', expecting 'WxH'", a.canvas)
+		}
+		result.canvas = &dimensions
+	}
+	if result.anchor, err = pbm.ParseAnchor(a.anchor); err != nil {
+		return nil, err
+	}
+	if result.fill, err = parseFill(a.fill); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// parseDimensions converts 'WxH' value to positive width and height
+func parseDimensions(value string) (img.Point, error) {
+	first, second, found := strings.Cut(value, "x")
+	if !found {
+		return img.Point{}, fmt.Errorf("missing 'x' separator")
+	}
+	width, errWidth := strconv.Atoi(first)
+	height, errHeight := strconv.Atoi(second)
+	if errWidth != nil || errHeight != nil || width < 0 || height < 0 {
+		return img.Point{}, fmt.Errorf("expecting positive integers")
+	}
+	return img.Point{width, height}, nil
+}
+
+// parseFill converts fill flag to color
+func parseFill(value string) (color.Color, error) {
+	switch value {
+	case "white":
+		return color.White, nil
+	case "black":
+		return color.Black, nil
+	case "transparent":
+		return color.Transparent, nil
+	}
+
+	invalid := fmt.Errorf("invalid fill '%s', expecting 'white', 'black', 'transparent' or '#rrggbb[aa]'", value)
+	if !strings.HasPrefix(value, "#") || (len(value) != 7 && len(value) != 9) {
+		return nil, invalid
+	}
+	components, err := strconv.ParseUint(value[1:], 16, 32)
+	if err != nil {
+		return nil, invalid
+	}
+	if len(value) == 7 {
+		components = components<<8 | 0xff
+	}
+	return color.NRGBA{uint8(components >> 24), uint8(components >> 16), uint8(components >> 8), uint8(components)}, nil
+}
+
+// parseCorners converts 'x1,y1,x2,y2,x3,y3,x4,y4' flag value to points
+func parseCorners(name string, value string) ([4]img.Point, error) {
+	var corners [4]img.Point
+	values := strings.Split(value, ",")
+	if len(values) != 8 {
+		return corners, fmt.Errorf("invalid %s corners '%s', expecting 'x1,y1,x2,y2,x3,y3,x4,y4'", name, value)
+	}
+	for cIdx := range corners {
+		x, errX := strconv.Atoi(values[cIdx*2])
+		y, errY := strconv.Atoi(values[cIdx*2+1])
+		if errX != nil || errY != nil {
+			return corners, fmt.Errorf("invalid %s corners '%s', expecting 'x1,y1,x2,y2,x3,y3,x4,y4'", name, value)
+		}
+		corners[cIdx] = img.Point{x, y}
+	}
+	return corners, nil
+}
+
+// options converts command line flags to image transformation settings
+func (a *App) options() ([]pbm.Option, error) {
+	opts := []pbm.Option{}
+	switch a.algorithm {
+	case "":
+		// warp subcommand, no rotation
+	case "rotator":
+		opts = append(opts, pbm.WithAlgorithm(pbm.AlgorithmRotator))
+	case "shear":
+		opts = append(opts, pbm.WithAlgorithm(pbm.AlgorithmShear))
+	default:
+		return nil, fmt.Errorf("unknown algorithm '%s', expecting 'rotator' or 'shear'", a.algorithm)
+	}
+	if a.resize {
+		opts = append(opts, pbm.WithResize())
+	}
+	if a.forward {
+		opts = append(opts, pbm.WithForwardMapping())
+	}
+	if a.center != "" {
+		center, err := a.centerOption()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, center)
+	}
+	opts = append(opts, pbm.WithWorkers(a.workers))
+	return opts, nil
+}
+
+// centerOption converts center flag to rotation center setting
+func (a *App) centerOption() (pbm.Option, error) {
+	if a.center == "centroid" {
+		return pbm.WithCentroid(), nil
+	}
+	if anchor, err := pbm.ParseAnchor(a.center); err == nil {
+		return pbm.WithAnchor(anchor), nil
+	}
+
+	invalid := fmt.Errorf("invalid center '%s', expecting 'x,y' coordinates, 'centroid' or anchor name", a.center)
+	first, second, found := strings.Cut(a.center, ",")
+	if !found {
+		return nil, invalid
+	}
+	x, err := strconv.ParseFloat(first, 64)
+	if err != nil {
+		return nil, invalid
+	}
+	y, err := strconv.ParseFloat(second, 64)
+	if err != nil {
+		return nil, invalid
+	}
+	return pbm.WithCenter(x, y), nil
+}
+
+// format validates output format, guessing it from output file extension
+// when not given
+func (a *App) format() (string, error) {
+	format := a.outputFormat
+	if format == "" {
+		format = "ascii"
+		if strings.EqualFold(filepath.Ext(a.outputFilePath), ".png") {
+			format = "png"
+		}
+	}
+
+	switch format {
+	case "ascii", "binary", "pam", "png":
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown format '%s', expecting 'ascii', 'binary', 'pam' or 'png'", format)
+	}
+}
+
+// decoder returns a function yielding successive images of input stream
+//
+// PNG input, detected from its signature, holds a single image converted to
+// black & white.
+func (a *App) decoder(input io.Reader) func() (pbm.Netpbm, error) {
+	reader := bufio.NewReader(input)
+	signature, _ := reader.Peek(len(pngSignature))
+	if !bytes.Equal(signature, pngSignature) {
+		return pbm.NewDecoder(reader).NextNetpbm
+	}
+
+	done := false
+	return func() (pbm.Netpbm, error) {
+		if done {
+			return nil, io.EOF
+		}
+		done = true
+		image, err := png.Decode(reader)
+		if err != nil {
+			return nil, fmt.Errorf("invalid png input: %s", err)
+		}
+		return pbm.NewImageFromImage(image), nil
+	}
+}
+
+// encoder returns a function writing successive images to output stream
+//
+// PNG output can only hold a single image.
+func (a *App) encoder(output io.Writer, format string) func(pbm.Netpbm) error {
+	if format == "png" {
+		count := 0
+		return func(image pbm.Netpbm) error {
+			count++
+			if count > 1 {
+				return fmt.Errorf("png format can't hold more than one image")
+			}
+			return png.Encode(output, image)
+		}
+	}
+
+	formats := map[string]pbm.Format{
+		"ascii":  pbm.FormatASCII,
+		"binary": pbm.FormatBinary,
+		"pam":    pbm.FormatPAM,
+	}
+	return pbm.NewEncoder(output, formats[format]).Encode
+}
+
+func (a *App) openInput() (io.ReadCloser, error) {
+	if a.inputFilePath == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	return os.Open(a.inputFilePath)
+}
+
+// committer - output stream which content is only kept once committed,
+// Close discards uncommitted content
+type committer interface {
+	io.WriteCloser
+	Commit() error
+}
+
+// openOutput opens output stream, see outputFile
+func (a *App) openOutput() (committer, error) {
+	if a.outputFilePath == "-" {
+		return nopWriteCloser{os.Stdout}, nil
+	}
+	return newOutputFile(a.outputFilePath)
+}
+
+// nopWriteCloser prevents closing standard output
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+func (nopWriteCloser) Commit() error {
+	return nil
+}
+
+// outputFile - temporary file in directory of output path, renamed over it
+// when committed so that existing output, which may be input itself, is left
+// untouched when processing fails
+type outputFile struct {
+	*os.File
+	path      string
+	committed bool
+}
+
+// newOutputFile creates temporary file for given output path, with
+// permissions of existing output or usual ones of a new file
+func newOutputFile(path string) (*outputFile, error) {
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return nil, err
+	}
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := file.Chmod(mode); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	return &outputFile{File: file, path: path}, nil
+}
+
+// Commit replaces output path by written content
+func (o *outputFile) Commit() error {
+	o.committed = true
+	if err := o.File.Close(); err != nil {
+		os.Remove(o.File.Name())
+		return err
+	}
+	if err := os.Rename(o.File.Name(), o.path); err != nil {
+		os.Remove(o.File.Name())
+		return err
+	}
 	return nil
 }
 
+// Close removes temporary file unless committed
+func (o *outputFile) Close() error {
+	if o.committed {
+		return nil
+	}
+	o.committed = true
+	o.File.Close()
+	return os.Remove(o.File.Name())
+}
+
 func main() {
 	app := NewApp()
 	if err := app.run(); err != nil {
@@ -0,0 +1,164 @@
+// Copyright 2023 Xavier MARCELET. All rights reserved.
+// Use of this source code is governed by Apache
+// license that can be found in the LICENSE file.
+
+package pbm
+
+import (
+	"fmt"
+	"image"
+	"math"
+)
+
+// Affine - 2x3 matrix of an affine transformation
+//
+// Values {a, b, c, d, e, f} map point (x,y) to (a*x + b*y + c, d*x + e*y + f).
+// Y axis points downward, positive angles rotate clockwise on screen.
+type Affine [6]float64
+
+// AffineIdentity returns transformation leaving points unchanged
+func AffineIdentity() Affine {
+	return Affine{1, 0, 0, 0, 1, 0}
+}
+
+// AffineRotate returns rotation of given angle in degrees around origin
+func AffineRotate(angle float64) Affine {
+	θ := angle * (math.Pi / float64(180))
+	sinθ, cosθ := math.Sin(θ), math.Cos(θ)
+	return Affine{cosθ, -sinθ, 0, sinθ, cosθ, 0}
+}
+
+// AffineScale returns scaling of given horizontal and vertical factors
+func AffineScale(sx, sy float64) Affine {
+	return Affine{sx, 0, 0, 0, sy, 0}
+}
+
+// AffineShear returns shearing of given factors, x is shifted by kx*y and y
+// by ky*x
+func AffineShear(kx, ky float64) Affine {
+	return Affine{1, kx, 0, ky, 1, 0}
+}
+
+// AffineTranslate returns translation of given offsets
+func AffineTranslate(tx, ty float64) Affine {
+	return Affine{1, 0, tx, 0, 1, ty}
+}
+
+// AffineCompose returns transformation applying given ones in order
+func AffineCompose(transforms ...Affine) Affine {
+	result := AffineIdentity()
+	for _, cNext := range transforms {
+		result = Affine{
+			cNext[0]*result[0] + cNext[1]*result[3],
+			cNext[0]*result[1] + cNext[1]*result[4],
+			cNext[0]*result[2] + cNext[1]*result[5] + cNext[2],
+			cNext[3]*result[0] + cNext[4]*result[3],
+			cNext[3]*result[1] + cNext[4]*result[4],
+			cNext[3]*result[2] + cNext[4]*result[5] + cNext[5],
+		}
+	}
+	return result
+}
+
+// Apply computes coordinates of transformed point
+func (a Affine) Apply(x float64, y float64) (float64, float64) {
+	return a[0]*x + a[1]*y + a[2], a[3]*x + a[4]*y + a[5]
+}
+
+// Invert returns the inverse transformation, fails when matrix is singular
+func (a Affine) Invert() (Affine, error) {
+	det := a[0]*a[4] - a[1]*a[3]
+	if det == 0 {
+		return Affine{}, fmt.Errorf("invalid transformation, matrix is not invertible")
+	}
+	return Affine{
+		a[4] / det,
+		-a[1] / det,
+		(a[1]*a[5] - a[4]*a[2]) / det,
+		-a[3] / det,
+		a[0] / det,
+		(a[3]*a[2] - a[0]*a[5]) / det,
+	}, nil
+}
+
+// Transformer computes transformed coordinates for a given (x,y) point
+// Stores constant values for a given transformation of a given image
+type Transformer struct {
+	width   int     // width of source image
+	height  int     // height of source image
+	x0      float64 // x coordinates of center of transformation
+	y0      float64 // y coordinates of center of transformation
+	offsetX float64 // shift of x coordinates for when center falls in inter-pixel
+	offsetY float64 // shift of y coordinates for when center falls in inter-pixel
+	matrix  Affine  // transformation relative to center
+	inverse Affine  // inverse of matrix
+}
+
+// NewTransformer initializes transformer object, fails when transformation
+// can't be inverted
+//
+// Transformation is applied to coordinates relative to the geometric center
+// of image unless WithCenter, WithAnchor or WithCentroid option is given.
+// Coordinates are those of pixel centers: scaling n pixels by factor k spans
+// (n-1)*k+1 pixels.
+//
+//  1. when center of transformation is on an inter-pixel for only one
+//     coordinates translate point to half a pixel
+func NewTransformer(affine Affine, img Sizer, opts ...Option) (*Transformer, error) {
+	inverse, err := affine.Invert()
+	if err != nil {
+		return nil, err
+	}
+
+	x0, y0 := newOptions(opts).pivot(img)
+	// 1.
+	offsetX, offsetY := halfOffset(x0, y0)
+
+	return &Transformer{
+		width:   img.Width(),
+		height:  img.Height(),
+		x0:      x0,
+		y0:      y0,
+		offsetX: offsetX,
+		offsetY: offsetY,
+		matrix:  affine,
+		inverse: inverse,
+	}, nil
+}
+
+// Compute computes pixel new coordinates after transformation
+func (t *Transformer) Compute(x int, y int) (int, int) {
+	x1, y1 := t.matrix.Apply(float64(x)-t.x0, float64(y)-t.y0)
+
+	return int(math.Round(x1 + t.x0 + t.offsetX)), int(math.Round(y1 + t.y0 + t.offsetY))
+}
+
+// Inverse computes source pixel coordinates of a pixel after transformation
+//
+// This is the exact inverse transformation of Compute before rounding.
+func (t *Transformer) Inverse(x int, y int) (int, int) {
+	x1, y1 := t.inverse.Apply(float64(x)-t.x0-t.offsetX, float64(y)-t.y0-t.offsetY)
+
+	return int(math.Round(x1 + t.x0)), int(math.Round(y1 + t.y0))
+}
+
+// Bounds computes the smallest rectangle holding all transformed pixels
+//
+// Transformation is linear and rounding is monotonic, transformed pixels
+// always fall within transformed corners.
+func (t *Transformer) Bounds() image.Rectangle {
+	var bounds image.Rectangle
+	if t.width == 0 || t.height == 0 {
+		return bounds
+	}
+	corners := [][2]int{{0, 0}, {t.width - 1, 0}, {0, t.height - 1}, {t.width - 1, t.height - 1}}
+	for cIdx, cCorner := range corners {
+		x, y := t.Compute(cCorner[0], cCorner[1])
+		corner := image.Rect(x, y, x+1, y+1)
+		if cIdx == 0 {
+			bounds = corner
+		}
+		bounds = bounds.Union(corner)
+	}
+	return bounds
+}
@@ -0,0 +1,101 @@
+// Copyright 2023 Xavier MARCELET. All rights reserved.
+// Use of this source code is governed by Apache
+// license that can be found in the LICENSE file.
+
+package pbm
+
+import (
+	"bytes"
+	"testing"
+)
+
+// transform gives operation applying given affine transformation
+func transform(affine Affine, opts ...Option) func(*Image) error {
+	return func(img *Image) error { return img.Transform(affine, opts...) }
+}
+
+func TestAffine_compose(t *testing.T) {
+	affine := AffineCompose(AffineTranslate(1, 2), AffineScale(2, 3))
+	if x, y := affine.Apply(1, 1); x != 4 || y != 9 {
+		t.Fatalf("unexpected point (%f,%f)", x, y)
+	}
+
+	affine = AffineCompose(AffineShear(1, 0), AffineTranslate(-1, 0))
+	if x, y := affine.Apply(1, 2); x != 2 || y != 2 {
+		t.Fatalf("unexpected point (%f,%f)", x, y)
+	}
+
+	inverse, err := affine.Invert()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if x, y := inverse.Apply(2, 2); x != 1 || y != 2 {
+		t.Fatalf("unexpected point (%f,%f)", x, y)
+	}
+
+	if _, err := AffineScale(0, 1).Invert(); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestTransform_rotate(t *testing.T) {
+	// rotation is a special case of affine transformation
+	in := `P1
+5 4
+10010
+01101
+00001
+11000
+`
+	for _, cAngle := range []float64{30, 45, -100} {
+		img, err := NewImageFromString(in)
+		if err != nil {
+			t.Fatalf("unexpected parse error: %s", err)
+		}
+		img.Rotate(cAngle, WithResize())
+		expected := bytes.Buffer{}
+		if err := img.EncodeASCII(&expected); err != nil {
+			t.Fatalf("unexpected encode error: %s", err)
+		}
+		checkOperation(t, in, expected.String(), transform(AffineRotate(cAngle), WithResize()))
+	}
+}
+
+func TestTransform_scale(t *testing.T) {
+	in := `P1
+3 2
+100
+001
+`
+	out := `P1
+5 3
+10000
+00011
+00011
+`
+	checkOperation(t, in, out, transform(AffineScale(2, 2), WithAnchor(AnchorTopLeft), WithResize()))
+
+	// scale and rotate in a single pass
+	out = `P1
+3 5
+001
+000
+000
+110
+110
+`
+	checkOperation(t, in, out, transform(AffineCompose(AffineScale(2, 2), AffineRotate(90)), WithAnchor(AnchorTopLeft), WithResize()))
+}
+
+func TestTransform_singular(t *testing.T) {
+	img, err := NewImageFromString("P1\n2 1\n10\n")
+	if err != nil {
+		t.Fatalf("unexpected parse error: %s", err)
+	}
+	if err := img.Transform(AffineScale(0, 0)); err == nil {
+		t.Fatalf("expected error")
+	}
+	if img.width != 2 || img.height != 1 || !img.at(0, 0) {
+		t.Fatalf("image must be left unchanged")
+	}
+}
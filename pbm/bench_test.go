@@ -0,0 +1,148 @@
+// Copyright 2023 Xavier MARCELET. All rights reserved.
+// Use of this source code is governed by Apache
+// license that can be found in the LICENSE file.
+
+package pbm
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+// benchSizes - dimensions of synthetic images used by benchmarks
+var benchSizes = []struct {
+	name string
+	size size
+}{
+	{"720p", size{1280, 720}},
+	{"1080p", size{1920, 1080}},
+	{"2160p", size{3840, 2160}},
+}
+
+// benchPages - synthetic images by size name, generated once
+var benchPages = map[string]*Image{}
+
+// syntheticPage returns a reproducible image looking like a scanned letter:
+// lines of glyphs made of random strokes, within page margins
+//
+// Benchmarks don't depend on any dataset file, they run anywhere.
+func syntheticPage(name string, dimensions size) *Image {
+	if page, ok := benchPages[name]; ok {
+		return page
+	}
+	var (
+		random = rand.New(rand.NewSource(int64(dimensions.width)))
+		page   = newImage(dimensions.width, dimensions.height)
+		margin = dimensions.width / 10
+		glyph  = dimensions.height / 60
+	)
+	for top := margin; top+glyph < dimensions.height-margin; top += 2 * glyph {
+		for left := margin; left+glyph < dimensions.width-margin; left += glyph {
+			// spaces between words
+			if random.Intn(6) == 0 {
+				continue
+			}
+			for y := top; y < top+glyph; y++ {
+				for x := left + 1; x < left+glyph-1; x++ {
+					if random.Intn(3) == 0 {
+						page.set(x, y, true)
+					}
+				}
+			}
+		}
+	}
+	benchPages[name] = page
+	return page
+}
+
+// clone returns a copy of image, so that in place operations can be repeated
+func (i *Image) clone() *Image {
+	result := *i
+	result.data = append([]uint64(nil), i.data...)
+	return &result
+}
+
+func BenchmarkParse(b *testing.B) {
+	encoders := map[string]func(*Image, io.Writer) error{
+		"ascii":  (*Image).EncodeASCII,
+		"binary": (*Image).EncodeBinary,
+	}
+	for _, cFormat := range []string{"ascii", "binary"} {
+		for _, cSize := range benchSizes {
+			writer := bytes.Buffer{}
+			if err := encoders[cFormat](syntheticPage(cSize.name, cSize.size), &writer); err != nil {
+				b.Fatalf("unexpected encode error: %s", err)
+			}
+			input := writer.Bytes()
+
+			b.Run(cFormat+"/"+cSize.name, func(b *testing.B) {
+				b.ReportAllocs()
+				b.SetBytes(int64(len(input)))
+				for cIdx := 0; cIdx < b.N; cIdx++ {
+					image := &Image{}
+					if err := image.parse(bytes.NewReader(input)); err != nil {
+						b.Fatalf("unexpected parse error: %s", err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkEncode(b *testing.B) {
+	encoders := map[string]func(*Image, io.Writer) error{
+		"ascii":  (*Image).EncodeASCII,
+		"binary": (*Image).EncodeBinary,
+		"pam":    (*Image).EncodePAM,
+	}
+	for _, cFormat := range []string{"ascii", "binary", "pam"} {
+		for _, cSize := range benchSizes {
+			image := syntheticPage(cSize.name, cSize.size)
+			b.Run(cFormat+"/"+cSize.name, func(b *testing.B) {
+				writer := bytes.Buffer{}
+				b.ReportAllocs()
+				for cIdx := 0; cIdx < b.N; cIdx++ {
+					writer.Reset()
+					if err := encoders[cFormat](image, &writer); err != nil {
+						b.Fatalf("unexpected encode error: %s", err)
+					}
+				}
+				b.SetBytes(int64(writer.Len()))
+			})
+		}
+	}
+}
+
+func BenchmarkRotate(b *testing.B) {
+	rotations := []struct {
+		name  string
+		angle float64
+		opts  []Option
+	}{
+		{"90", 90, nil},
+		{"180", 180, nil},
+		{"270", 270, nil},
+		{"12.5", 12.5, nil},
+		{"12.5-resize", 12.5, []Option{WithResize()}},
+		{"12.5-shear", 12.5, []Option{WithAlgorithm(AlgorithmShear)}},
+		{"12.5-forward", 12.5, []Option{WithForwardMapping()}},
+		{"-45-resize", -45, []Option{WithResize()}},
+	}
+	for _, cRotation := range rotations {
+		for _, cSize := range benchSizes {
+			page := syntheticPage(cSize.name, cSize.size)
+			b.Run(fmt.Sprintf("%s/%s", cRotation.name, cSize.name), func(b *testing.B) {
+				b.ReportAllocs()
+				for cIdx := 0; cIdx < b.N; cIdx++ {
+					b.StopTimer()
+					image := page.clone()
+					b.StartTimer()
+					image.Rotate(cRotation.angle, cRotation.opts...)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,512 @@
+// Copyright 2023 Xavier MARCELET. All rights reserved.
+// Use of this source code is governed by Apache
+// license that can be found in the LICENSE file.
+
+package pbm
+
+import (
+	"image"
+	"math/bits"
+)
+
+// wordBits - number of pixels packed in a word of Image data
+const wordBits = 64
+
+// words gives number of words holding a row of given width
+func words(width int) int {
+	return (width + wordBits - 1) / wordBits
+}
+
+// mask gives bit of pixel at given column within its word, first pixel of a
+// word is its most significant bit
+func mask(x int) uint64 {
+	return 1 << (wordBits - 1 - x%wordBits)
+}
+
+// newImage allocates white image of given dimensions
+func newImage(width, height int) *Image {
+	stride := words(width)
+	return &Image{
+		width:  width,
+		height: height,
+		stride: stride,
+		data:   make([]uint64, stride*height),
+	}
+}
+
+// newImageFromPixels packs given one-per-pixel values, true is black
+func newImageFromPixels(width, height int, pixels []bool) *Image {
+	image := newImage(width, height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if pixels[x+y*width] {
+				image.data[x/wordBits+y*image.stride] |= mask(x)
+			}
+		}
+	}
+	return image
+}
+
+// pixels unpacks image to one value per pixel, true is black
+func (i *Image) pixels() []bool {
+	result := make([]bool, i.width*i.height)
+	i.blackPixels(func(x, y int) {
+		result[x+y*i.width] = true
+	})
+	return result
+}
+
+// at tells if pixel at given coordinates is black
+func (i *Image) at(x, y int) bool {
+	return i.data[x/wordBits+y*i.stride]&mask(x) != 0
+}
+
+// set changes pixel at given coordinates
+func (i *Image) set(x, y int, black bool) {
+	if black {
+		i.data[x/wordBits+y*i.stride] |= mask(x)
+	} else {
+		i.data[x/wordBits+y*i.stride] &^= mask(x)
+	}
+}
+
+// row gives words of given row
+func (i *Image) row(y int) []uint64 {
+	return i.data[y*i.stride : (y+1)*i.stride]
+}
+
+// tail gives mask of pixels held by last word of rows, other bits are
+// padding which is always zero
+func (i *Image) tail() uint64 {
+	if i.width%wordBits == 0 {
+		return ^uint64(0)
+	}
+	return ^uint64(0) << (wordBits - i.width%wordBits)
+}
+
+// count gives number of black pixels
+func (i *Image) count() int {
+	result := 0
+	for _, cWord := range i.data {
+		result += bits.OnesCount64(cWord)
+	}
+	return result
+}
+
+// blackPixels calls given function for each black pixel, row by row, white
+// words are skipped at once
+func (i *Image) blackPixels(fn func(x, y int)) {
+	for y := 0; y < i.height; y++ {
+		for cIdx, cWord := range i.row(y) {
+			for cWord != 0 {
+				bit := bits.LeadingZeros64(cWord)
+				fn(cIdx*wordBits+bit, y)
+				cWord &^= 1 << (wordBits - 1 - bit)
+			}
+		}
+	}
+}
+
+// flipRow writes given row of given width mirrored left to right to dest
+//
+// Reversing all words of a row mirrors it as if its width was a multiple of
+// 64, so that padding comes first and must be shifted out.
+func flipRow(dest, src []uint64, width int) {
+	var (
+		count   = len(src)
+		padding = count*wordBits - width
+	)
+	for cIdx := range dest {
+		word := bits.Reverse64(src[count-1-cIdx]) << padding
+		if padding != 0 && cIdx+1 < count {
+			word |= bits.Reverse64(src[count-2-cIdx]) >> (wordBits - padding)
+		}
+		dest[cIdx] = word
+	}
+}
+
+// transposeBlock transposes 64x64 pixels block in place, bit j of word i
+// becomes bit i of word j
+//
+// Block is split in four quadrants, top-right and bottom-left ones are
+// swapped, recursively down to single bits (Hacker's Delight, 7-3).
+func transposeBlock(block *[wordBits]uint64) {
+	half, lower := wordBits/2, uint64(0x00000000ffffffff)
+	for half != 0 {
+		for k := 0; k < wordBits; k = (k + half + 1) &^ half {
+			swap := (block[k] ^ (block[k+half] >> half)) & lower
+			block[k] ^= swap
+			block[k+half] ^= swap << half
+		}
+		half >>= 1
+		lower ^= lower << half
+	}
+}
+
+// transposeBits returns image mirrored along its top-left to bottom-right
+// diagonal, processed by blocks of 64x64 pixels
+//
+// Bands of 64 source rows are processed by given number of workers, each one
+// fills its own column of result words.
+//
+//  1. rows below image are white, they become padding of result
+func transposeBits(src *Image, workers int) *Image {
+	result := newImage(src.height, src.width)
+	parallelRows(words(src.height), workers, func(first, last int) {
+		var block [wordBits]uint64
+		for top := first * wordBits; top < last*wordBits; top += wordBits {
+			rows := src.height - top
+			if rows > wordBits {
+				rows = wordBits
+			}
+			for column := 0; column < src.stride; column++ {
+				for k := 0; k < wordBits; k++ {
+					block[k] = 0
+					// 1.
+					if k < rows {
+						block[k] = src.data[column+(top+k)*src.stride]
+					}
+				}
+				transposeBlock(&block)
+				for k := 0; k < wordBits && column*wordBits+k < src.width; k++ {
+					result.data[top/wordBits+(column*wordBits+k)*result.stride] = block[k]
+				}
+			}
+		}
+	})
+	return result
+}
+
+// transposedOrientations - orientation remaining to apply after
+// transposition, for orientations that swap size
+var transposedOrientations = map[Orientation]Orientation{
+	OrientationTranspose:  OrientationNormal,
+	OrientationRotate90:   OrientationFlipHorizontal,
+	OrientationTransverse: OrientationRotate180,
+	OrientationRotate270:  OrientationFlipVertical,
+}
+
+// orientBits returns a copy of image with given orientation applied
+//
+// Every orientation is a transposition, if it swaps size, followed by a
+// combination of flips. Vertical flips copy whole rows, horizontal ones
+// reverse words. Bands of rows are processed by given number of workers.
+func orientBits(src *Image, orientation Orientation, workers int) *Image {
+	if orientation.swapsSize() {
+		src, orientation = transposeBits(src, workers), transposedOrientations[orientation]
+		if orientation == OrientationNormal {
+			return src
+		}
+	}
+	var (
+		result     = newImage(src.width, src.height)
+		vertical   = orientation == OrientationFlipVertical || orientation == OrientationRotate180
+		horizontal = orientation == OrientationFlipHorizontal || orientation == OrientationRotate180
+	)
+	parallelRows(src.height, workers, func(top, bottom int) {
+		for y := top; y < bottom; y++ {
+			sourceY := y
+			if vertical {
+				sourceY = src.height - 1 - y
+			}
+			if horizontal {
+				flipRow(result.row(y), src.row(sourceY), src.width)
+			} else {
+				copy(result.row(y), src.row(sourceY))
+			}
+		}
+	})
+	return result
+}
+
+// mapBits returns image transformed by given mapper, see rotatePixels
+//
+// Bands of destination rows are processed by given number of workers, forward
+// mapping is sequential since any source pixel may land in any band and only
+// visits black pixels.
+func mapBits(src *Image, rotator mapper, bounds image.Rectangle, forward bool, workers int) *Image {
+	if forward {
+		result := newImage(bounds.Dx(), bounds.Dy())
+		src.blackPixels(func(x, y int) {
+			pixelX, pixelY := rotator.Compute(x, y)
+			if (image.Point{pixelX, pixelY}.In(bounds)) {
+				result.set(pixelX-bounds.Min.X, pixelY-bounds.Min.Y, true)
+			}
+		})
+		return result
+	}
+	return inverseBits(src, rotator, bounds, workers)
+}
+
+// inverser computes source coordinates of transformed pixels, see mapper
+type inverser interface {
+	Inverse(x int, y int) (int, int)
+}
+
+// inverseBits returns image which pixels in given bounds are sampled from
+// source through given inverse mapping, see inversePixels
+func inverseBits(src *Image, rotator inverser, bounds image.Rectangle, workers int) *Image {
+	result := newImage(bounds.Dx(), bounds.Dy())
+	renderer := &inverseRenderer{dst: result, src: src, rotator: rotator, bounds: bounds}
+	parallelRows(result.height, workers, renderer.rows)
+	return result
+}
+
+// inverseRenderer - samples pixels in bounds from source through inverse
+// mapping, rows are stored into first rows of destination from given word
+//
+// Fields may be changed between renderings so that a single rows function
+// is given to parallelRows, see Strips.Encode.
+type inverseRenderer struct {
+	dst     *Image
+	column  int // first word of destination rows
+	src     *Image
+	rotator inverser
+	bounds  image.Rectangle
+}
+
+// rows renders given band of rows
+//
+//  1. each destination word is built before being stored
+func (r *inverseRenderer) rows(top, bottom int) {
+	width := r.bounds.Dx()
+	for y := top; y < bottom; y++ {
+		row := r.dst.row(y)[r.column:]
+		// 1.
+		word := uint64(0)
+		for x := 0; x < width; x++ {
+			sourceX, sourceY := r.rotator.Inverse(x+r.bounds.Min.X, y+r.bounds.Min.Y)
+			if sourceX >= 0 && sourceX < r.src.width && sourceY >= 0 && sourceY < r.src.height && r.src.at(sourceX, sourceY) {
+				word |= mask(x)
+			}
+			if x%wordBits == wordBits-1 || x == width-1 {
+				row[x/wordBits] = word
+				word = 0
+			}
+		}
+	}
+}
+
+// transformBits returns image transformed according to given transformation,
+// see apply
+func transformBits(src *Image, r *transformation) *Image {
+	if r.turns != 0 {
+		src = orientBits(src, turnOrientations[r.turns], r.workers)
+	}
+	if r.mapper == nil {
+		return src
+	}
+	return mapBits(src, r.mapper, r.bounds, r.forward, r.workers)
+}
+
+// shiftedWord gives 64 pixels of given row starting at given column, which
+// may be out of row, pixels out of row are white
+func shiftedWord(row []uint64, start int) uint64 {
+	switch {
+	case len(row) == 0 || start <= -wordBits || start >= len(row)*wordBits:
+		return 0
+	case start < 0:
+		return row[0] >> -start
+	}
+	index, offset := start/wordBits, start%wordBits
+	word := row[index] << offset
+	if offset != 0 && index+1 < len(row) {
+		word |= row[index+1] >> (wordBits - offset)
+	}
+	return word
+}
+
+// spanMask gives mask of pixels of a word starting at given column which
+// fall within [0, width)
+func spanMask(start, width int) uint64 {
+	low, high := -start, width-start
+	if low < 0 {
+		low = 0
+	}
+	if high > wordBits {
+		high = wordBits
+	}
+	if high <= low {
+		return 0
+	}
+	return ^uint64(0) >> low &^ (^uint64(0) >> high)
+}
+
+// frameBits returns pixels of given rectangle of image, parts of rectangle
+// outside of image are filled with given pixel, see framePixels
+//
+// Each result word is made of one or two source words shifted to the left
+// edge of rectangle. Bands of rows are processed by given number of workers.
+//
+//  1. rows out of image have no source words, they are made of fill only
+func frameBits(src *Image, rect image.Rectangle, fill bool, workers int) *Image {
+	result := newImage(rect.Dx(), rect.Dy())
+	parallelRows(result.height, workers, func(top, bottom int) {
+		for y := top; y < bottom; y++ {
+			var (
+				dest  = result.row(y)
+				row   []uint64
+				width int
+			)
+			// 1.
+			if sourceY := y + rect.Min.Y; sourceY >= 0 && sourceY < src.height {
+				row, width = src.row(sourceY), src.width
+			}
+			for cIdx := range dest {
+				start := cIdx*wordBits + rect.Min.X
+				dest[cIdx] = shiftedWord(row, start)
+				if fill {
+					dest[cIdx] |= ^spanMask(start, width)
+				}
+			}
+			if result.stride != 0 {
+				dest[result.stride-1] &= result.tail()
+			}
+		}
+	})
+	return result
+}
+
+// nearestBits returns image scaled copying source pixel closest to center of
+// each destination pixel, see nearestPixels
+func nearestBits(src *Image, newWidth, newHeight, workers int) *Image {
+	var (
+		result  = newImage(newWidth, newHeight)
+		columns = make([]int, newWidth)
+	)
+	for x := range columns {
+		columns[x] = (2*x + 1) * src.width / (2 * newWidth)
+	}
+	parallelRows(newHeight, workers, func(top, bottom int) {
+		for y := top; y < bottom; y++ {
+			sourceY := (2*y + 1) * src.height / (2 * newHeight)
+			row := result.row(y)
+			for x, cColumn := range columns {
+				if src.at(cColumn, sourceY) {
+					row[x/wordBits] |= mask(x)
+				}
+			}
+		}
+	})
+	return result
+}
+
+// boxBits returns image scaled so that destination pixels are black when at
+// least half of source area they cover is black, see boxPixels
+func boxBits(src *Image, newWidth, newHeight, workers int) *Image {
+	var (
+		result  = newImage(newWidth, newHeight)
+		columns = coverages(src.width, newWidth)
+		rows    = coverages(src.height, newHeight)
+	)
+	parallelRows(newHeight, workers, func(top, bottom int) {
+		for y := top; y < bottom; y++ {
+			row := result.row(y)
+			for x, cColumn := range columns {
+				var black, total float64
+				for _, cY := range rows[y] {
+					for _, cX := range cColumn {
+						weight := cX.weight * cY.weight
+						if src.at(cX.index, cY.index) {
+							black += weight
+						}
+						total += weight
+					}
+				}
+				if black*2 >= total {
+					row[x/wordBits] |= mask(x)
+				}
+			}
+		}
+	})
+	return result
+}
+
+// spreadBits moves bit i of given value to bit 2i
+func spreadBits(value uint32) uint64 {
+	result := uint64(value)
+	result = (result | result<<16) & 0x0000ffff0000ffff
+	result = (result | result<<8) & 0x00ff00ff00ff00ff
+	result = (result | result<<4) & 0x0f0f0f0f0f0f0f0f
+	result = (result | result<<2) & 0x3333333333333333
+	result = (result | result<<1) & 0x5555555555555555
+	return result
+}
+
+// epxBits doubles image size with EPX algorithm, see epxPixels
+//
+// Rules are evaluated on 64 pixels at once: neighbours words are source
+// words, or words shifted by one pixel for left and right ones. Pixels of
+// both corners of a destination row are interleaved into two words. Bands of
+// source rows are processed by given number of workers.
+//
+//  1. out of bound neighbours are P itself
+//  2. padding of P is white but its left neighbours aren't, corners are
+//     cleared past image width
+func epxBits(src *Image, workers int) *Image {
+	result := newImage(2*src.width, 2*src.height)
+	parallelRows(src.height, workers, func(top, bottom int) {
+		for y := top; y < bottom; y++ {
+			var (
+				row          = src.row(y)
+				above, below = row, row
+				first        = result.row(2 * y)
+				second       = result.row(2*y + 1)
+			)
+			// 1.
+			if y > 0 {
+				above = src.row(y - 1)
+			}
+			if y < src.height-1 {
+				below = src.row(y + 1)
+			}
+			for cIdx, p := range row {
+				a, b, c, d, valid := above[cIdx], p<<1, p>>1, below[cIdx], ^uint64(0)
+				if cIdx > 0 {
+					c |= row[cIdx-1] << (wordBits - 1)
+				} else {
+					c |= p & mask(0)
+				}
+				if cIdx+1 < len(row) {
+					b |= row[cIdx+1] >> (wordBits - 1)
+				} else {
+					last := mask(src.width - 1)
+					b = b&^last | p&last
+					// 2.
+					valid = src.tail()
+				}
+				var (
+					oneRule   = ^(c ^ a) & (c ^ d) & (a ^ b)
+					twoRule   = ^(a ^ b) & (a ^ c) & (b ^ d)
+					threeRule = ^(d ^ c) & (d ^ b) & (c ^ a)
+					fourRule  = ^(b ^ d) & (b ^ a) & (d ^ c)
+					one       = (p&^oneRule | a&oneRule) & valid
+					two       = (p&^twoRule | b&twoRule) & valid
+					three     = (p&^threeRule | c&threeRule) & valid
+					four      = (p&^fourRule | d&fourRule) & valid
+				)
+				first[2*cIdx] = spreadBits(uint32(one>>32))<<1 | spreadBits(uint32(two>>32))
+				second[2*cIdx] = spreadBits(uint32(three>>32))<<1 | spreadBits(uint32(four>>32))
+				if 2*cIdx+1 < result.stride {
+					first[2*cIdx+1] = spreadBits(uint32(one))<<1 | spreadBits(uint32(two))
+					second[2*cIdx+1] = spreadBits(uint32(three))<<1 | spreadBits(uint32(four))
+				}
+			}
+		}
+	})
+	return result
+}
+
+// scaleBits returns image scaled to given size with given filter, by given
+// number of workers, see scalePixels
+func scaleBits(src *Image, newWidth, newHeight int, filter Filter, workers int) *Image {
+	switch filter {
+	case FilterBox:
+		return boxBits(src, newWidth, newHeight, workers)
+	case FilterEPX:
+		for src.width > 0 && src.height > 0 && 2*src.width <= newWidth && 2*src.height <= newHeight {
+			src = epxBits(src, workers)
+		}
+	}
+	return nearestBits(src, newWidth, newHeight, workers)
+}
@@ -0,0 +1,155 @@
+// Copyright 2023 Xavier MARCELET. All rights reserved.
+// Use of this source code is governed by Apache
+// license that can be found in the LICENSE file.
+
+package pbm
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+)
+
+// randomPixels returns reproducible random pixels
+func randomPixels(width, height int) []bool {
+	random := rand.New(rand.NewSource(int64(width*height + 1)))
+	result := make([]bool, width*height)
+	for cIdx := range result {
+		result[cIdx] = random.Intn(2) == 1
+	}
+	return result
+}
+
+func checkPixels(t *testing.T, image *Image, width, height int, pixels []bool) {
+	t.Helper()
+
+	if image.width != width || image.height != height {
+		t.Fatalf("unexpected size %dx%d, want %dx%d", image.width, image.height, width, height)
+	}
+	for cIdx, cPixel := range image.pixels() {
+		if cPixel != pixels[cIdx] {
+			t.Fatalf("unexpected pixel at (%d,%d)", cIdx%width, cIdx/width)
+		}
+	}
+	for y := 0; y < image.height && image.stride != 0; y++ {
+		if image.row(y)[image.stride-1]&^image.tail() != 0 {
+			t.Fatalf("unexpected padding bits on row %d", y)
+		}
+	}
+}
+
+func TestBitmap_orient(t *testing.T) {
+	// sizes below, at and across word boundaries
+	for _, cSize := range []size{{1, 1}, {3, 2}, {64, 64}, {65, 3}, {130, 70}} {
+		pixels := randomPixels(cSize.width, cSize.height)
+		for orientation := OrientationNormal; orientation <= OrientationRotate270; orientation++ {
+			image := newImageFromPixels(cSize.width, cSize.height, pixels)
+			if err := image.Orient(orientation); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			width, height := cSize.width, cSize.height
+			if orientation.swapsSize() {
+				width, height = height, width
+			}
+			checkPixels(t, image, width, height, orientPixels(pixels, cSize.width, cSize.height, orientation, 1))
+		}
+	}
+}
+
+func TestBitmap_rotate(t *testing.T) {
+	pixels := randomPixels(100, 70)
+	for _, cAngle := range []float64{12.5, -30, 45} {
+		for _, cForward := range []bool{false, true} {
+			opts := []Option{WithResize()}
+			if cForward {
+				opts = append(opts, WithForwardMapping())
+			}
+			image := newImageFromPixels(100, 70, pixels)
+			image.Rotate(cAngle, opts...)
+
+			rotation := newRotation(cAngle, size{100, 70}, opts)
+			expected := apply(rotation, pixels, 100, 70, false)
+			checkPixels(t, image, rotation.bounds.Dx(), rotation.bounds.Dy(), expected)
+		}
+	}
+}
+
+func TestBitmap_frame(t *testing.T) {
+	pixels := randomPixels(130, 70)
+	rects := []image.Rectangle{
+		image.Rect(0, 0, 130, 70),
+		image.Rect(1, 2, 3, 4),
+		image.Rect(63, 0, 129, 70),
+		image.Rect(-70, -3, 200, 75),
+		image.Rect(-64, 10, -1, 20),
+		image.Rect(140, -10, 300, 5),
+	}
+	for _, cRect := range rects {
+		for _, cFill := range []color.Color{White, Black} {
+			img := newImageFromPixels(130, 70, pixels)
+			result := img.Pad(-cRect.Min.Y, cRect.Max.X-130, cRect.Max.Y-70, -cRect.Min.X, cFill)
+			expected := framePixels(pixels, 130, 70, cRect, isBlack(cFill), 1)
+			checkPixels(t, result, cRect.Dx(), cRect.Dy(), expected)
+		}
+	}
+}
+
+func TestBitmap_scale(t *testing.T) {
+	coverage := func(pixels []bool, weights []float64) bool {
+		var black, total float64
+		for cIdx, cPixel := range pixels {
+			if cPixel {
+				black += weights[cIdx]
+			}
+			total += weights[cIdx]
+		}
+		return black*2 >= total
+	}
+	// sizes below, at and across word boundaries
+	sizes := []size{{1, 1}, {3, 2}, {33, 5}, {64, 64}, {65, 3}, {130, 70}}
+	for _, cSize := range sizes {
+		pixels := randomPixels(cSize.width, cSize.height)
+		for _, cTarget := range []size{{1, 1}, {7, 3}, {2 * cSize.width, 2 * cSize.height}, {5*cSize.width + 1, 4 * cSize.height}} {
+			for _, cFilter := range []Filter{FilterNearest, FilterBox, FilterEPX} {
+				image := newImageFromPixels(cSize.width, cSize.height, pixels)
+				if err := image.Scale(cTarget.width, cTarget.height, cFilter); err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				expected := scalePixels(pixels, cSize.width, cSize.height, cTarget.width, cTarget.height, cFilter, 1, coverage)
+				checkPixels(t, image, cTarget.width, cTarget.height, expected)
+			}
+		}
+	}
+}
+
+func TestBitmap_binaryPadding(t *testing.T) {
+	// padding bits of input are set, they must not become pixels
+	image, err := NewImageFromString("P4\n3 2\n\xff\xbf")
+	if err != nil {
+		t.Fatalf("unexpected parse error: %s", err)
+	}
+	image.Rotate(180)
+	writer := bytes.Buffer{}
+	if err := image.EncodeASCII(&writer); err != nil {
+		t.Fatalf("unexpected encode error: %s", err)
+	}
+	if writer.String() != "P1\n3 2\n101\n111\n" {
+		t.Fatalf("unexpected output: %s", writer.String())
+	}
+}
+
+func TestBitmap_binaryRoundTrip(t *testing.T) {
+	pixels := randomPixels(150, 3)
+	image := newImageFromPixels(150, 3, pixels)
+	binary := bytes.Buffer{}
+	if err := image.EncodeBinary(&binary); err != nil {
+		t.Fatalf("unexpected encode error: %s", err)
+	}
+	decoded, err := NewImageFromString(binary.String())
+	if err != nil {
+		t.Fatalf("unexpected parse error: %s", err)
+	}
+	checkPixels(t, decoded, 150, 3, pixels)
+}
@@ -0,0 +1,163 @@
+// Copyright 2023 Xavier MARCELET. All rights reserved.
+// Use of this source code is governed by Apache
+// license that can be found in the LICENSE file.
+
+package pbm
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Anchor - named position of an image
+type Anchor int
+
+const (
+	AnchorCenter Anchor = iota
+	AnchorTopLeft
+	AnchorTop
+	AnchorTopRight
+	AnchorLeft
+	AnchorRight
+	AnchorBottomLeft
+	AnchorBottom
+	AnchorBottomRight
+)
+
+// anchorNames - names of anchors, as accepted by ParseAnchor
+var anchorNames = []string{
+	AnchorCenter:      "center",
+	AnchorTopLeft:     "top-left",
+	AnchorTop:         "top",
+	AnchorTopRight:    "top-right",
+	AnchorLeft:        "left",
+	AnchorRight:       "right",
+	AnchorBottomLeft:  "bottom-left",
+	AnchorBottom:      "bottom",
+	AnchorBottomRight: "bottom-right",
+}
+
+// ParseAnchor returns anchor of given name
+func ParseAnchor(name string) (Anchor, error) {
+	for cIdx, cName := range anchorNames {
+		if cName == name {
+			return Anchor(cIdx), nil
+		}
+	}
+	return AnchorCenter, fmt.Errorf("unknown anchor '%s', expecting %s", name, strings.Join(anchorNames, ", "))
+}
+
+// String gives name of anchor
+func (a Anchor) String() string {
+	if int(a) < 0 || int(a) >= len(anchorNames) {
+		return fmt.Sprintf("Anchor(%d)", int(a))
+	}
+	return anchorNames[a]
+}
+
+// Point gives pixel coordinates of anchor in given image
+//
+// Center of even-sized images falls between two pixels.
+func (a Anchor) Point(img Sizer) (float64, float64) {
+	x := float64(img.Width()-1) / 2.0
+	y := float64(img.Height()-1) / 2.0
+	switch a {
+	case AnchorTopLeft, AnchorLeft, AnchorBottomLeft:
+		x = 0
+	case AnchorTopRight, AnchorRight, AnchorBottomRight:
+		x = float64(img.Width() - 1)
+	}
+	switch a {
+	case AnchorTopLeft, AnchorTop, AnchorTopRight:
+		y = 0
+	case AnchorBottomLeft, AnchorBottom, AnchorBottomRight:
+		y = float64(img.Height() - 1)
+	}
+	return x, y
+}
+
+// centroider is implemented by images able to locate their content
+type centroider interface {
+	centroid() (float64, float64, bool)
+}
+
+// centroid computes mean coordinates of non-blank pixels, last value is false
+// when all pixels are blank
+func centroid[T comparable](data []T, width int, blank T) (float64, float64, bool) {
+	var sumX, sumY, count float64
+	for cIdx, cPixel := range data {
+		if cPixel == blank {
+			continue
+		}
+		sumX += float64(cIdx % width)
+		sumY += float64(cIdx / width)
+		count++
+	}
+	if count == 0 {
+		return 0, 0, false
+	}
+	return sumX / count, sumY / count, true
+}
+
+// centroid of black pixels
+func (i *Image) centroid() (float64, float64, bool) {
+	var sumX, sumY, count float64
+	i.blackPixels(func(x, y int) {
+		sumX, sumY, count = sumX+float64(x), sumY+float64(y), count+1
+	})
+	if count == 0 {
+		return 0, 0, false
+	}
+	return sumX / count, sumY / count, true
+}
+
+// centroid of non-white pixels
+func (g *GrayImage) centroid() (float64, float64, bool) {
+	return centroid(g.data, g.width, uint16(g.maxval))
+}
+
+// centroid of non-white pixels
+func (c *ColorImage) centroid() (float64, float64, bool) {
+	return centroid(c.data, c.width, c.white())
+}
+
+// pivot computes center of rotation of given image according to options,
+// geometric center by default
+func (o *options) pivot(img Sizer) (float64, float64) {
+	if o.center != nil {
+		if x, y, ok := o.center(img); ok {
+			return x, y
+		}
+	}
+	return AnchorCenter.Point(img)
+}
+
+// turnPoint gives coordinates of a point after given number of clockwise
+// quarter turns of an image of given size, see turnPixels
+func turnPoint(x, y float64, img Sizer, turns int) (float64, float64) {
+	width, height := float64(img.Width()-1), float64(img.Height()-1)
+	switch turns {
+	case 1:
+		return height - y, x
+	case 2:
+		return width - x, height - y
+	case 3:
+		return y, width - x
+	}
+	return x, y
+}
+
+// halfOffset gives shift of rotated coordinates when center of rotation is on
+// an inter-pixel for only one coordinate, translating points to half a pixel
+func halfOffset(x0, y0 float64) (float64, float64) {
+	fracX := x0 - math.Floor(x0)
+	fracY := y0 - math.Floor(y0)
+	switch {
+	case fracX == 0 && fracY == 0.5:
+		return 0.5, 0
+	case fracX == 0.5 && fracY == 0:
+		return 0, 0.5
+	}
+	return 0, 0
+}
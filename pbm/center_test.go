@@ -0,0 +1,90 @@
+// Copyright 2023 Xavier MARCELET. All rights reserved.
+// Use of this source code is governed by Apache
+// license that can be found in the LICENSE file.
+
+package pbm
+
+import (
+	"testing"
+)
+
+func TestParseAnchor(t *testing.T) {
+	anchor, err := ParseAnchor("bottom-right")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if x, y := anchor.Point(&Image{width: 4, height: 3}); x != 3 || y != 2 {
+		t.Fatalf("unexpected point (%f,%f)", x, y)
+	}
+	if x, y := AnchorCenter.Point(&Image{width: 4, height: 3}); x != 1.5 || y != 1 {
+		t.Fatalf("unexpected point (%f,%f)", x, y)
+	}
+	if anchor.String() != "bottom-right" {
+		t.Fatalf("unexpected name '%s'", anchor.String())
+	}
+
+	if _, err := ParseAnchor("middle"); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestRotate_anchor(t *testing.T) {
+	// right angle keeps source frame around explicit center
+	in := `P1
+3 3
+110
+000
+000
+`
+	out := `P1
+3 3
+100
+100
+000
+`
+	checkRotation(t, 90, in, out, WithAnchor(AnchorTopLeft))
+	checkRotation(t, 90, in, out, WithAnchor(AnchorTopLeft), WithAlgorithm(AlgorithmShear))
+}
+
+func TestRotate_center(t *testing.T) {
+	in := `P1
+3 2
+001
+001
+`
+	out := `P1
+3 2
+001
+000
+`
+	checkRotation(t, 180, in, out, WithCenter(2, 0))
+}
+
+func TestRotate_centroid(t *testing.T) {
+	// subject stays in place when rotating around its centroid
+	in := `P1
+4 4
+0000
+0000
+0010
+0001
+`
+	checkRotation(t, 180, in, in, WithCentroid())
+	checkRotation(t, 180, in, in, WithCentroid(), WithAlgorithm(AlgorithmShear))
+
+	out := `P1
+4 4
+1000
+0100
+0000
+0000
+`
+	checkRotation(t, 180, in, out)
+
+	// blank image rotates around geometric center
+	in = `P1
+2 1
+00
+`
+	checkRotation(t, 45, in, in, WithCentroid())
+}
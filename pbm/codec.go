@@ -0,0 +1,45 @@
+// Copyright 2023 Xavier MARCELET. All rights reserved.
+// Use of this source code is governed by Apache
+// license that can be found in the LICENSE file.
+
+package pbm
+
+import (
+	"image"
+	"io"
+)
+
+// registers PBM plain and raw formats so that image.Decode recognizes them
+// once this package is imported
+func init() {
+	image.RegisterFormat("pbm", PBMMagicP1, Decode, DecodeConfig)
+	image.RegisterFormat("pbm", PBMMagicP4, Decode, DecodeConfig)
+}
+
+// Decode reads a PBM image from given stream, returned value is an *Image
+func Decode(stream io.Reader) (image.Image, error) {
+	img, err := NewDecoder(stream).Next()
+	if err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+// DecodeConfig reads PBM image dimensions from given stream without reading
+// its data section
+func DecodeConfig(stream io.Reader) (image.Config, error) {
+	decoder := NewDecoder(stream)
+	if _, err := decoder.magic(PBMMagicP1, PBMMagicP4); err != nil {
+		return image.Config{}, err
+	}
+
+	img := &Image{}
+	if err := img.decodeHeader(decoder); err != nil {
+		return image.Config{}, err
+	}
+	return image.Config{
+		ColorModel: Model,
+		Width:      img.width,
+		Height:     img.height,
+	}, nil
+}
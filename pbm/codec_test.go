@@ -0,0 +1,64 @@
+// Copyright 2023 Xavier MARCELET. All rights reserved.
+// Use of this source code is governed by Apache
+// license that can be found in the LICENSE file.
+
+package pbm
+
+import (
+	"image"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestCodec_decode(t *testing.T) {
+	for _, input := range []string{"P1 2 2 1001", "P4 2 2 \x80\x40"} {
+		img, format, err := image.Decode(strings.NewReader(input))
+		if err != nil {
+			t.Fatalf("unexpected decode error: %s", err)
+		}
+		if format != "pbm" {
+			t.Fatalf("unexpected format '%s'", format)
+		}
+		bitmap, ok := img.(*Image)
+		if !ok {
+			t.Fatalf("unexpected image type %T", img)
+		}
+		expect(t, bitmap, nil, 2, 2, "1001")
+	}
+}
+
+func TestCodec_decodeConfig(t *testing.T) {
+	_, srcPath, _, ok := runtime.Caller(0)
+	if !ok {
+		t.Fatalf("could not determine current source file path")
+	}
+	testPath := filepath.Join(filepath.Dir(srcPath), "..", "dataset", "720p.pbm")
+	file, err := os.Open(testPath)
+	if err != nil {
+		t.Fatalf("could not read test file '%s': %s", testPath, err)
+	}
+	defer file.Close()
+
+	config, format, err := image.DecodeConfig(file)
+	if err != nil {
+		t.Fatalf("unexpected decode error: %s", err)
+	}
+	if format != "pbm" || config.Width != 1280 || config.Height != 720 || config.ColorModel == nil {
+		t.Fatalf("unexpected config: %s %v", format, config)
+	}
+}
+
+func TestCodec_invalid(t *testing.T) {
+	if _, err := Decode(strings.NewReader("P2 1 1 1 1")); err == nil {
+		t.Fatalf("should have fail: not a bitmap")
+	}
+	if _, err := DecodeConfig(strings.NewReader("P2 1 1 1 1")); err == nil {
+		t.Fatalf("should have fail: not a bitmap")
+	}
+	if _, err := DecodeConfig(strings.NewReader("P1 x 1 1")); err == nil {
+		t.Fatalf("should have fail: invalid width")
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2023 Xavier MARCELET. All rights reserved.
+// Use of this source code is governed by Apache
+// license that can be found in the LICENSE file.
+
+package pbm
+
+import (
+	"strings"
+)
+
+// PPMMagicP3 is the special magic header of PPM color image format
+// see https://en.wikipedia.org/wiki/Netpbm
+const PPMMagicP3 string = "P3"
+
+// PPMMagicP6 is the special magic header of PPM color raw image format,
+// samples are stored on 1 byte, or 2 bytes when maxval exceeds 255
+const PPMMagicP6 string = "P6"
+
+// rgb - red, green and blue samples of a color pixel
+type rgb struct {
+	r uint16
+	g uint16
+	b uint16
+}
+
+// ColorImage - Represent a PPM image
+//
+// Pixel with all samples at 0 is black, all samples at maxval is white.
+// Optional alpha channel, only available from PAM format, has sample value 0
+// for fully transparent pixels.
+type ColorImage struct {
+	width  int
+	height int
+	maxval int
+	data   []rgb
+	alpha  []uint16
+}
+
+// Creates ColorImage object from given string
+func NewColorImageFromString(value string) (*ColorImage, error) {
+	reader := strings.NewReader(value)
+	image := &ColorImage{}
+	if err := image.parse(reader); err != nil {
+		return nil, err
+	}
+	return image, nil
+}
+
+// Creates ColorImage object from given file path
+func NewColorImageFromFile(path string) (*ColorImage, error) {
+	image := &ColorImage{}
+	if err := decodeFromFile(path, image.parse); err != nil {
+		return nil, err
+	}
+	return image, nil
+}
+
+// Returns image's width
+func (c *ColorImage) Width() int {
+	return c.width
+}
+
+// Returns image's height
+func (c *ColorImage) Height() int {
+	return c.height
+}
+
+// Returns image's maximum sample value
+func (c *ColorImage) Maxval() int {
+	return c.maxval
+}
+
+// HasAlpha tells if image has an alpha channel
+func (c *ColorImage) HasAlpha() bool {
+	return c.alpha != nil
+}
+
+// white returns the pixel with all samples at maxval
+func (c *ColorImage) white() rgb {
+	return rgb{uint16(c.maxval), uint16(c.maxval), uint16(c.maxval)}
+}
@@ -0,0 +1,34 @@
+// Copyright 2023 Xavier MARCELET. All rights reserved.
+// Use of this source code is governed by Apache
+// license that can be found in the LICENSE file.
+
+package pbm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestColor_doesNotExist(t *testing.T) {
+	_, err := NewColorImageFromFile("/dos/not/exist")
+	if err == nil {
+		t.Fatalf("should have fail, path does not exist")
+	}
+}
+
+func TestColor_fromFile(t *testing.T) {
+	image := ColorImage{2, 1, 255, []rgb{{255, 0, 0}, {0, 0, 255}}, nil}
+	dir, err := os.MkdirTemp("", "test")
+	if err != nil {
+		t.Fatalf("could not create temp directory")
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "output")
+	if err := image.EncodeBinaryToFile(path); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	decoded, err := NewColorImageFromFile(path)
+	expectColor(t, decoded, err, 2, 1, 255, image.data)
+}
@@ -0,0 +1,145 @@
+// Copyright 2023 Xavier MARCELET. All rights reserved.
+// Use of this source code is governed by Apache
+// license that can be found in the LICENSE file.
+
+package pbm
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// Colors of Image pixels
+var (
+	Black = color.Gray{Y: 0}
+	White = color.Gray{Y: 255}
+)
+
+// Palette holds both colors of Image pixels
+var Palette = color.Palette{Black, White}
+
+// Model is the color model of Image, colors are converted as by Set, see
+// isBlack
+var Model = color.ModelFunc(blackOrWhite)
+
+// ensures Image can be used with image/draw and image encoders
+var _ draw.Image = &Image{}
+
+// Bounds returns image's domain, always starting at (0,0)
+func (i *Image) Bounds() image.Rectangle {
+	return image.Rect(0, 0, i.width, i.height)
+}
+
+// ColorModel returns Model, converting any color to black or white
+func (i *Image) ColorModel() color.Model {
+	return Model
+}
+
+// At returns color of pixel at given coordinates, white when out of bounds
+func (i *Image) At(x, y int) color.Color {
+	if !(image.Point{x, y}.In(i.Bounds())) || !i.at(x, y) {
+		return White
+	}
+	return Black
+}
+
+// Set changes pixel at given coordinates to black or white, see isBlack,
+// coordinates out of bounds are ignored
+func (i *Image) Set(x, y int, c color.Color) {
+	if !(image.Point{x, y}.In(i.Bounds())) {
+		return
+	}
+	i.set(x, y, isBlack(c))
+}
+
+// isBlack converts color to black & white
+//
+// Color is composed over a white background, it is black when its luminance
+// is lower than half of full intensity.
+//
+//  1. color components are alpha-premultiplied, adding missing opacity gives
+//     composition over white
+//  2. same luminance weights as color.GrayModel
+func isBlack(c color.Color) bool {
+	r, g, b, a := c.RGBA()
+	// 1.
+	r, g, b = r+0xffff-a, g+0xffff-a, b+0xffff-a
+	// 2.
+	luminance := (19595*r + 38470*g + 7471*b + 1<<15) >> 16
+	return luminance < 0x8000
+}
+
+// blackOrWhite converts given color to Black or White, see isBlack
+func blackOrWhite(c color.Color) color.Color {
+	if isBlack(c) {
+		return Black
+	}
+	return White
+}
+
+// NewImageFromImage converts any image to black & white, see isBlack
+func NewImageFromImage(src image.Image) *Image {
+	bounds := src.Bounds()
+	result := newImage(bounds.Dx(), bounds.Dy())
+	for y := 0; y < result.height; y++ {
+		for x := 0; x < result.width; x++ {
+			result.set(x, y, isBlack(src.At(bounds.Min.X+x, bounds.Min.Y+y)))
+		}
+	}
+	return result
+}
+
+// scale converts sample of given maxval to 16 bits color component
+func scale(sample uint16, maxval int) uint16 {
+	return uint16(uint32(sample) * 0xffff / uint32(maxval))
+}
+
+// Bounds returns image's domain, always starting at (0,0)
+func (g *GrayImage) Bounds() image.Rectangle {
+	return image.Rect(0, 0, g.width, g.height)
+}
+
+// ColorModel returns color.Gray16Model, or color.NRGBA64Model when image has
+// an alpha channel
+func (g *GrayImage) ColorModel() color.Model {
+	if g.HasAlpha() {
+		return color.NRGBA64Model
+	}
+	return color.Gray16Model
+}
+
+// At returns color of pixel at given coordinates, white when out of bounds
+func (g *GrayImage) At(x, y int) color.Color {
+	if !(image.Point{x, y}.In(g.Bounds())) {
+		return color.White
+	}
+	value := scale(g.data[x+y*g.width], g.maxval)
+	if g.HasAlpha() {
+		return color.NRGBA64{value, value, value, scale(g.alpha[x+y*g.width], g.maxval)}
+	}
+	return color.Gray16{Y: value}
+}
+
+// Bounds returns image's domain, always starting at (0,0)
+func (c *ColorImage) Bounds() image.Rectangle {
+	return image.Rect(0, 0, c.width, c.height)
+}
+
+// ColorModel returns color.NRGBA64Model
+func (c *ColorImage) ColorModel() color.Model {
+	return color.NRGBA64Model
+}
+
+// At returns color of pixel at given coordinates, white when out of bounds
+func (c *ColorImage) At(x, y int) color.Color {
+	if !(image.Point{x, y}.In(c.Bounds())) {
+		return color.White
+	}
+	pixel := c.data[x+y*c.width]
+	alpha := uint16(0xffff)
+	if c.HasAlpha() {
+		alpha = scale(c.alpha[x+y*c.width], c.maxval)
+	}
+	return color.NRGBA64{scale(pixel.r, c.maxval), scale(pixel.g, c.maxval), scale(pixel.b, c.maxval), alpha}
+}
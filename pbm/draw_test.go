@@ -0,0 +1,114 @@
+// Copyright 2023 Xavier MARCELET. All rights reserved.
+// Use of this source code is governed by Apache
+// license that can be found in the LICENSE file.
+
+package pbm
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"testing"
+)
+
+func TestDraw_at(t *testing.T) {
+	img := newImageFromPixels(2, 1, []bool{true, false})
+	if img.Bounds() != image.Rect(0, 0, 2, 1) {
+		t.Fatalf("unexpected bounds: %v", img.Bounds())
+	}
+	if img.At(0, 0) != Black || img.At(1, 0) != White {
+		t.Fatalf("unexpected colors: %v %v", img.At(0, 0), img.At(1, 0))
+	}
+	if img.At(-1, 0) != White || img.At(2, 0) != White || img.At(0, 1) != White {
+		t.Fatalf("out of bounds pixels should be white")
+	}
+}
+
+func TestDraw_set(t *testing.T) {
+	img := newImageFromPixels(6, 1, []bool{false, false, true, true, true, false})
+	img.Set(0, 0, color.RGBA{10, 20, 30, 255})
+	img.Set(1, 0, color.Gray16{0x9000})
+	img.Set(2, 0, color.White)
+	// transparent colors are composed over white, as by NewImageFromImage
+	img.Set(3, 0, color.Transparent)
+	img.Set(4, 0, color.NRGBA{0, 0, 0, 64})
+	img.Set(5, 0, color.NRGBA{0, 0, 0, 200})
+	img.Set(6, 0, color.Black)
+	expect(t, img, nil, 6, 1, "100001")
+}
+
+func TestDraw_colorModel(t *testing.T) {
+	colors := []color.Color{
+		color.Black,
+		color.White,
+		color.Transparent,
+		color.NRGBA{0, 0, 0, 64},
+		color.NRGBA{0, 0, 0, 200},
+		color.RGBA{10, 20, 30, 255},
+		color.Gray16{0x9000},
+	}
+	img := newImage(1, 1)
+	for _, cColor := range colors {
+		img.Set(0, 0, cColor)
+		if converted := img.ColorModel().Convert(cColor); converted != img.At(0, 0) {
+			t.Fatalf("unexpected conversion of %v: %v, Set gives %v", cColor, converted, img.At(0, 0))
+		}
+	}
+}
+
+func TestDraw_stdlib(t *testing.T) {
+	// draw an opaque black square onto a white bitmap, then encode to png
+	img := newImage(4, 4)
+	draw.Draw(img, image.Rect(1, 1, 3, 3), image.NewUniform(color.Black), image.Point{}, draw.Src)
+	expect(t, img, nil, 4, 4, "0000"+"0110"+"0110"+"0000")
+
+	output := bytes.Buffer{}
+	if err := png.Encode(&output, img); err != nil {
+		t.Fatalf("unexpected png encode error: %s", err)
+	}
+	decoded, err := png.Decode(&output)
+	if err != nil {
+		t.Fatalf("unexpected png decode error: %s", err)
+	}
+	if r, _, _, _ := decoded.At(1, 1).RGBA(); r != 0 {
+		t.Fatalf("unexpected png color at (1,1): %v", decoded.At(1, 1))
+	}
+	if r, _, _, _ := decoded.At(0, 0).RGBA(); r != 0xffff {
+		t.Fatalf("unexpected png color at (0,0): %v", decoded.At(0, 0))
+	}
+}
+
+func TestDraw_fromImage(t *testing.T) {
+	// transparent pixels are composed over white
+	src := image.NewNRGBA(image.Rect(10, 10, 14, 11))
+	src.Set(10, 10, color.NRGBA{0, 0, 0, 255})
+	src.Set(11, 10, color.NRGBA{200, 200, 200, 255})
+	src.Set(12, 10, color.NRGBA{0, 0, 0, 0})
+	src.Set(13, 10, color.NRGBA{0, 0, 200, 255})
+	img := NewImageFromImage(src)
+	expect(t, img, nil, 4, 1, "1001")
+}
+
+func TestDraw_grayAndColor(t *testing.T) {
+	gray := &GrayImage{2, 1, 255, []uint16{0, 255}, []uint16{255, 0}}
+	if gray.ColorModel() != color.NRGBA64Model || gray.Bounds() != image.Rect(0, 0, 2, 1) {
+		t.Fatalf("unexpected color model or bounds")
+	}
+	if c := gray.At(0, 0); c != (color.NRGBA64{0, 0, 0, 0xffff}) {
+		t.Fatalf("unexpected color: %v", c)
+	}
+	gray.alpha = nil
+	if c := gray.At(1, 0); c != (color.Gray16{0xffff}) {
+		t.Fatalf("unexpected color: %v", c)
+	}
+
+	colored := &ColorImage{1, 1, 1023, []rgb{{1023, 0, 0}}, nil}
+	if c := colored.At(0, 0); c != (color.NRGBA64{0xffff, 0, 0, 0xffff}) {
+		t.Fatalf("unexpected color: %v", c)
+	}
+	if colored.At(1, 0) != color.White || gray.At(0, 1) != color.White {
+		t.Fatalf("out of bounds pixels should be white")
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright 2023 Xavier MARCELET. All rights reserved.
+// Use of this source code is governed by Apache
+// license that can be found in the LICENSE file.
+
+package pbm
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// framePixels returns pixels of given rectangle of image, parts of rectangle
+// outside of image are filled with given pixel, bands of rows are processed
+// by given number of workers
+func framePixels[T any](data []T, width, height int, rect image.Rectangle, fill T, workers int) []T {
+	result := make([]T, rect.Dx()*rect.Dy())
+	parallelRows(rect.Dy(), workers, func(top, bottom int) {
+		for y := rect.Min.Y + top; y < rect.Min.Y+bottom; y++ {
+			for x := rect.Min.X; x < rect.Max.X; x++ {
+				pixel := fill
+				if x >= 0 && x < width && y >= 0 && y < height {
+					pixel = data[x+y*width]
+				}
+				result[x-rect.Min.X+(y-rect.Min.Y)*rect.Dx()] = pixel
+			}
+		}
+	})
+	return result
+}
+
+// cropRect restricts rectangle to image bounds
+func cropRect(img Sizer, rect image.Rectangle) image.Rectangle {
+	return rect.Intersect(image.Rect(0, 0, img.Width(), img.Height()))
+}
+
+// padRect gives rectangle of image extended by given margins, negative
+// margins remove pixels
+func padRect(img Sizer, top, right, bottom, left int) image.Rectangle {
+	if img.Width()+left+right < 0 || img.Height()+top+bottom < 0 {
+		return image.Rectangle{}
+	}
+	return image.Rect(-left, -top, img.Width()+right, img.Height()+bottom)
+}
+
+// canvasRect gives rectangle of given size which anchor matches the same
+// anchor of image
+//
+// When centers don't fall on the same pixel grid, image is shifted by half a
+// pixel toward bottom-right corner.
+func canvasRect(img Sizer, width, height int, anchor Anchor) image.Rectangle {
+	if width < 0 || height < 0 {
+		return image.Rectangle{}
+	}
+	x, y := anchor.Point(img)
+	canvasX, canvasY := anchor.Point(size{width, height})
+	offset := image.Point{int(math.Floor(x - canvasX)), int(math.Floor(y - canvasY))}
+	return image.Rect(0, 0, width, height).Add(offset)
+}
+
+// unscale converts 16 bits color component to sample of given maxval
+func unscale(component uint16, maxval int) uint16 {
+	return uint16((uint32(component)*uint32(maxval) + 0x7fff) / 0xffff)
+}
+
+// grayFill converts color to gray sample and alpha value of given maxval
+func grayFill(fill color.Color, maxval int) alphaPixel[uint16] {
+	value := color.NRGBA64Model.Convert(fill).(color.NRGBA64)
+	gray := color.Gray16Model.Convert(color.NRGBA64{value.R, value.G, value.B, 0xffff}).(color.Gray16)
+	return alphaPixel[uint16]{unscale(gray.Y, maxval), unscale(value.A, maxval)}
+}
+
+// colorFill converts color to rgb samples and alpha value of given maxval
+func colorFill(fill color.Color, maxval int) alphaPixel[rgb] {
+	value := color.NRGBA64Model.Convert(fill).(color.NRGBA64)
+	pixel := rgb{unscale(value.R, maxval), unscale(value.G, maxval), unscale(value.B, maxval)}
+	return alphaPixel[rgb]{pixel, unscale(value.A, maxval)}
+}
+
+// frame returns new image made of given rectangle of image, only WithWorkers
+// option applies
+func (i *Image) frame(rect image.Rectangle, fill color.Color, opts []Option) *Image {
+	return frameBits(i, rect, isBlack(fill), newOptions(opts).workers)
+}
+
+// Crop returns new image made of given rectangle of image, restricted to
+// image bounds
+func (i *Image) Crop(rect image.Rectangle, opts ...Option) *Image {
+	return i.frame(cropRect(i, rect), White, opts)
+}
+
+// Pad returns new image extended by given margins filled with given color
+// converted to black or white as by Set, negative margins remove pixels
+func (i *Image) Pad(top, right, bottom, left int, fill color.Color, opts ...Option) *Image {
+	return i.frame(padRect(i, top, right, bottom, left), fill, opts)
+}
+
+// Canvas returns new image of given size holding image at given anchor,
+// uncovered area is filled with given color converted to black or white as
+// by Set and image is cropped when larger than canvas
+func (i *Image) Canvas(width, height int, anchor Anchor, fill color.Color, opts ...Option) *Image {
+	return i.frame(canvasRect(i, width, height, anchor), fill, opts)
+}
+
+// frame returns new image made of given rectangle of image, fill alpha is
+// ignored when image has no alpha channel, only WithWorkers option applies
+func (g *GrayImage) frame(rect image.Rectangle, fill color.Color, opts []Option) *GrayImage {
+	workers := newOptions(opts).workers
+	pixel := grayFill(fill, g.maxval)
+	result := &GrayImage{
+		width:  rect.Dx(),
+		height: rect.Dy(),
+		maxval: g.maxval,
+		data:   framePixels(g.data, g.width, g.height, rect, pixel.pixel, workers),
+	}
+	if g.HasAlpha() {
+		result.alpha = framePixels(g.alpha, g.width, g.height, rect, pixel.alpha, workers)
+	}
+	return result
+}
+
+// Crop returns new image made of given rectangle of image, restricted to
+// image bounds
+func (g *GrayImage) Crop(rect image.Rectangle, opts ...Option) *GrayImage {
+	return g.frame(cropRect(g, rect), color.White, opts)
+}
+
+// Pad returns new image extended by given margins filled with given color,
+// negative margins remove pixels
+func (g *GrayImage) Pad(top, right, bottom, left int, fill color.Color, opts ...Option) *GrayImage {
+	return g.frame(padRect(g, top, right, bottom, left), fill, opts)
+}
+
+// Canvas returns new image of given size holding image at given anchor,
+// uncovered area is filled with given color and image is cropped when larger
+// than canvas
+func (g *GrayImage) Canvas(width, height int, anchor Anchor, fill color.Color, opts ...Option) *GrayImage {
+	return g.frame(canvasRect(g, width, height, anchor), fill, opts)
+}
+
+// frame returns new image made of given rectangle of image, fill alpha is
+// ignored when image has no alpha channel, only WithWorkers option applies
+func (c *ColorImage) frame(rect image.Rectangle, fill color.Color, opts []Option) *ColorImage {
+	workers := newOptions(opts).workers
+	pixel := colorFill(fill, c.maxval)
+	result := &ColorImage{
+		width:  rect.Dx(),
+		height: rect.Dy(),
+		maxval: c.maxval,
+		data:   framePixels(c.data, c.width, c.height, rect, pixel.pixel, workers),
+	}
+	if c.HasAlpha() {
+		result.alpha = framePixels(c.alpha, c.width, c.height, rect, pixel.alpha, workers)
+	}
+	return result
+}
+
+// Crop returns new image made of given rectangle of image, restricted to
+// image bounds
+func (c *ColorImage) Crop(rect image.Rectangle, opts ...Option) *ColorImage {
+	return c.frame(cropRect(c, rect), color.White, opts)
+}
+
+// Pad returns new image extended by given margins filled with given color,
+// negative margins remove pixels
+func (c *ColorImage) Pad(top, right, bottom, left int, fill color.Color, opts ...Option) *ColorImage {
+	return c.frame(padRect(c, top, right, bottom, left), fill, opts)
+}
+
+// Canvas returns new image of given size holding image at given anchor,
+// uncovered area is filled with given color and image is cropped when larger
+// than canvas
+func (c *ColorImage) Canvas(width, height int, anchor Anchor, fill color.Color, opts ...Option) *ColorImage {
+	return c.frame(canvasRect(c, width, height, anchor), fill, opts)
+}
@@ -0,0 +1,99 @@
+// Copyright 2023 Xavier MARCELET. All rights reserved.
+// Use of this source code is governed by Apache
+// license that can be found in the LICENSE file.
+
+package pbm
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestCrop(t *testing.T) {
+	in := `P1
+3 2
+100
+011
+`
+	crop := func(rect image.Rectangle) func(*Image) error {
+		return func(img *Image) error {
+			*img = *img.Crop(rect)
+			return nil
+		}
+	}
+	checkOperation(t, in, "P1\n2 2\n00\n11\n", crop(image.Rect(1, 0, 3, 2)))
+	// rectangle is restricted to image bounds
+	checkOperation(t, in, "P1\n2 1\n00\n", crop(image.Rect(1, -1, 5, 1)))
+	checkOperation(t, in, "P1\n0 0\n", crop(image.Rect(4, 4, 5, 5)))
+
+	// source image is left untouched
+	img, err := NewImageFromString(in)
+	if err != nil {
+		t.Fatalf("unexpected parse error: %s", err)
+	}
+	if img.Crop(image.Rect(0, 0, 1, 1)); img.Width() != 3 || img.Height() != 2 {
+		t.Fatalf("unexpected source size %dx%d", img.Width(), img.Height())
+	}
+}
+
+func TestPad(t *testing.T) {
+	in := `P1
+3 2
+100
+011
+`
+	pad := func(top, right, bottom, left int, fill color.Color) func(*Image) error {
+		return func(img *Image) error {
+			*img = *img.Pad(top, right, bottom, left, fill)
+			return nil
+		}
+	}
+	checkOperation(t, in, "P1\n5 3\n11111\n11100\n11011\n", pad(1, 0, 0, 2, Black))
+	checkOperation(t, in, "P1\n4 3\n1000\n0110\n0000\n", pad(0, 1, 1, 0, White))
+	// transparent fills are composed over white
+	checkOperation(t, in, "P1\n4 2\n1000\n0110\n", pad(0, 1, 0, 0, color.Transparent))
+	checkOperation(t, in, "P1\n4 2\n1001\n0111\n", pad(0, 1, 0, 0, color.NRGBA{0, 0, 0, 200}))
+	// negative margins remove pixels
+	checkOperation(t, in, "P1\n2 1\n11\n", pad(-1, 0, 0, -1, White))
+	checkOperation(t, in, "P1\n0 0\n", pad(-2, 0, -2, 0, White))
+}
+
+func TestCanvas(t *testing.T) {
+	in := `P1
+3 2
+100
+011
+`
+	canvas := func(width, height int, anchor Anchor, fill color.Color) func(*Image) error {
+		return func(img *Image) error {
+			*img = *img.Canvas(width, height, anchor, fill)
+			return nil
+		}
+	}
+	checkOperation(t, in, "P1\n5 4\n00000\n01000\n00110\n00000\n", canvas(5, 4, AnchorCenter, White))
+	checkOperation(t, in, "P1\n4 3\n1111\n1100\n1011\n", canvas(4, 3, AnchorBottomRight, Black))
+	checkOperation(t, in, "P1\n4 3\n0100\n0011\n0000\n", canvas(4, 3, AnchorTop, White))
+	checkOperation(t, in, "P1\n4 3\n0100\n0011\n0000\n", canvas(4, 3, AnchorTop, color.Transparent))
+	// larger images are cropped
+	checkOperation(t, in, "P1\n2 1\n10\n", canvas(2, 1, AnchorTopLeft, White))
+	checkOperation(t, in, "P1\n1 2\n0\n1\n", canvas(1, 2, AnchorRight, White))
+}
+
+func TestCanvasColor_fill(t *testing.T) {
+	gray := &GrayImage{1, 1, 255, []uint16{10}, []uint16{255}}
+	result := gray.Canvas(3, 1, AnchorCenter, color.Gray{128})
+	if result.data[0] != 128 || result.alpha[0] != 255 || gray.Width() != 1 {
+		t.Fatalf("unexpected pixels %v, alpha %v", result.data, result.alpha)
+	}
+	result = gray.Canvas(2, 1, AnchorLeft, color.Transparent)
+	if result.data[1] != 0 || result.alpha[1] != 0 {
+		t.Fatalf("unexpected pixels %v, alpha %v", result.data, result.alpha)
+	}
+
+	rgba := &ColorImage{1, 1, 15, []rgb{{1, 2, 3}}, nil}
+	padded := rgba.Pad(0, 0, 1, 0, color.RGBA{255, 0, 0, 255})
+	if padded.data[1] != (rgb{15, 0, 0}) || padded.alpha != nil {
+		t.Fatalf("unexpected pixels %v, alpha %v", padded.data, padded.alpha)
+	}
+}
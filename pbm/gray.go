@@ -0,0 +1,81 @@
+// Copyright 2023 Xavier MARCELET. All rights reserved.
+// Use of this source code is governed by Apache
+// license that can be found in the LICENSE file.
+
+package pbm
+
+import (
+	"strings"
+)
+
+// PGMMagicP2 is the special magic header of PGM grayscale image format
+// see https://en.wikipedia.org/wiki/Netpbm
+const PGMMagicP2 string = "P2"
+
+// PGMMagicP5 is the special magic header of PGM grayscale raw image format,
+// samples are stored on 1 byte, or 2 bytes when maxval exceeds 255
+const PGMMagicP5 string = "P5"
+
+// PGMMaxval is the highest maxval allowed by PGM and PPM formats
+const PGMMaxval int = 65535
+
+// GrayImage - Represent a PGM image
+//
+// Sample value 0 is black, maxval is white. Optional alpha channel, only
+// available from PAM format, has sample value 0 for fully transparent pixels.
+type GrayImage struct {
+	width  int
+	height int
+	maxval int
+	data   []uint16
+	alpha  []uint16
+}
+
+// Creates GrayImage object from given string
+func NewGrayImageFromString(value string) (*GrayImage, error) {
+	reader := strings.NewReader(value)
+	image := &GrayImage{}
+	if err := image.parse(reader); err != nil {
+		return nil, err
+	}
+	return image, nil
+}
+
+// Creates GrayImage object from given file path
+func NewGrayImageFromFile(path string) (*GrayImage, error) {
+	image := &GrayImage{}
+	if err := decodeFromFile(path, image.parse); err != nil {
+		return nil, err
+	}
+	return image, nil
+}
+
+// Returns image's width
+func (g *GrayImage) Width() int {
+	return g.width
+}
+
+// Returns image's height
+func (g *GrayImage) Height() int {
+	return g.height
+}
+
+// Returns image's maximum sample value, the white level
+func (g *GrayImage) Maxval() int {
+	return g.maxval
+}
+
+// HasAlpha tells if image has an alpha channel
+func (g *GrayImage) HasAlpha() bool {
+	return g.alpha != nil
+}
+
+// Threshold converts image to black & white, samples strictly lower than
+// given level become black pixels. Alpha channel is dropped.
+func (g *GrayImage) Threshold(level int) *Image {
+	image := newImage(g.width, g.height)
+	for cIdx, cSample := range g.data {
+		image.set(cIdx%g.width, cIdx/g.width, int(cSample) < level)
+	}
+	return image
+}
@@ -0,0 +1,40 @@
+// Copyright 2023 Xavier MARCELET. All rights reserved.
+// Use of this source code is governed by Apache
+// license that can be found in the LICENSE file.
+
+package pbm
+
+import (
+	"testing"
+)
+
+func TestGray_doesNotExist(t *testing.T) {
+	_, err := NewGrayImageFromFile("/dos/not/exist")
+	if err == nil {
+		t.Fatalf("should have fail, path does not exist")
+	}
+}
+
+func TestGray_threshold(t *testing.T) {
+	gray, err := NewGrayImageFromString("P2 3 2 255 0 127 128 255 200 10")
+	if err != nil {
+		t.Fatalf("unexpected parse error: %s", err)
+	}
+	image := gray.Threshold(128)
+	expect(t, image, nil, 3, 2, "110001")
+}
+
+func TestGray_promote(t *testing.T) {
+	image, err := NewImageFromString("P1 2 2 1001")
+	if err != nil {
+		t.Fatalf("unexpected parse error: %s", err)
+	}
+	gray := image.ToGray(255)
+	expectGray(t, gray, nil, 2, 2, 255, []uint16{0, 255, 255, 0})
+
+	if back := gray.Threshold(128); back == nil {
+		t.Fatalf("unexpected nil image")
+	} else {
+		expect(t, back, nil, 2, 2, "1001")
+	}
+}
@@ -16,11 +16,19 @@ import (
 // see https://en.wikipedia.org/wiki/Netpbm
 const PBMMagicP1 string = "P1"
 
+// PBMMagicP4 is the special magic header of PBM black&white raw image format,
+// pixels are packed 8 per byte
+const PBMMagicP4 string = "P4"
+
 // Image - Represent a PBM image
+//
+// Pixels are packed 64 per word, most significant bit first, set bits being
+// black. Each row starts on a new word, padding bits are always zero.
 type Image struct {
 	width  int
 	height int
-	data   []bool
+	stride int      // number of words per row
+	data   []uint64 // rows of packed pixels
 }
 
 // Creates Image object from given string
@@ -35,24 +43,25 @@ func NewImageFromString(value string) (*Image, error) {
 
 // Creates Image object from given file path
 func NewImageFromFile(path string) (*Image, error) {
-	var reader io.Reader
+	image := &Image{}
+	if err := decodeFromFile(path, image.parse); err != nil {
+		return nil, err
+	}
+	return image, nil
+}
 
+// decodeFromFile opens given file path, '-' meaning stdin, and calls decode on it
+func decodeFromFile(path string, decode func(io.Reader) error) error {
 	if path == "-" {
-		reader = os.Stdin
-	} else {
-		file, err := os.Open(path)
-		if err != nil {
-			return nil, err
-		}
-		defer file.Close()
-		reader = file
+		return decode(os.Stdin)
 	}
 
-	image := &Image{}
-	if err := image.parse(reader); err != nil {
-		return nil, err
+	file, err := os.Open(path)
+	if err != nil {
+		return err
 	}
-	return image, nil
+	defer file.Close()
+	return decode(file)
 }
 
 // Returns image's width
@@ -64,3 +73,22 @@ func (i *Image) Width() int {
 func (i *Image) Height() int {
 	return i.height
 }
+
+// ToGray promotes image to grayscale, black pixels get value 0 and white
+// pixels get given maxval
+func (i *Image) ToGray(maxval int) *GrayImage {
+	gray := &GrayImage{
+		width:  i.width,
+		height: i.height,
+		maxval: maxval,
+		data:   make([]uint16, i.width*i.height),
+	}
+	for y := 0; y < i.height; y++ {
+		for x := 0; x < i.width; x++ {
+			if !i.at(x, y) {
+				gray.data[x+y*i.width] = uint16(maxval)
+			}
+		}
+	}
+	return gray
+}
@@ -0,0 +1,70 @@
+// Copyright 2023 Xavier MARCELET. All rights reserved.
+// Use of this source code is governed by Apache
+// license that can be found in the LICENSE file.
+
+package pbm
+
+import (
+	"image"
+	"io"
+	"strings"
+)
+
+// Sizer is implemented by any image exposing its dimensions
+type Sizer interface {
+	Width() int
+	Height() int
+}
+
+// Netpbm is implemented by all supported Netpbm image types
+type Netpbm interface {
+	Sizer
+	image.Image
+	Rotate(angle float64, opts ...Option)
+	Transform(affine Affine, opts ...Option) error
+	Warp(from, to [4]image.Point, opts ...Option) error
+	Orient(orientation Orientation, opts ...Option) error
+	FlipHorizontal()
+	FlipVertical()
+	Transpose()
+	Transverse()
+	Scale(newWidth, newHeight int, filter Filter, opts ...Option) error
+	EncodeASCII(stream io.Writer) error
+	EncodeASCIIToFile(path string) error
+	EncodeBinary(stream io.Writer) error
+	EncodeBinaryToFile(path string) error
+	EncodePAM(stream io.Writer) error
+	EncodePAMToFile(path string) error
+}
+
+// Creates image object from given string, its type depends on magic number
+func NewNetpbmFromString(value string) (Netpbm, error) {
+	return newNetpbm(strings.NewReader(value))
+}
+
+// Creates image object from given file path, its type depends on magic number
+func NewNetpbmFromFile(path string) (Netpbm, error) {
+	var img Netpbm
+	err := decodeFromFile(path, func(stream io.Reader) error {
+		var err error
+		img, err = newNetpbm(stream)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+// newNetpbm parses a single image from stream, its type depends on magic number
+func newNetpbm(stream io.Reader) (Netpbm, error) {
+	decoder := NewDecoder(stream)
+	img, err := decoder.NextNetpbm()
+	if err != nil {
+		return nil, err
+	}
+	if err := decoder.end(); err != nil {
+		return nil, err
+	}
+	return img, nil
+}
@@ -0,0 +1,46 @@
+// Copyright 2023 Xavier MARCELET. All rights reserved.
+// Use of this source code is governed by Apache
+// license that can be found in the LICENSE file.
+
+package pbm
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestNetpbm_dispatch(t *testing.T) {
+	inputs := map[string]string{
+		"P1 1 1 1":       "*pbm.Image",
+		"P4 1 1 \x80":    "*pbm.Image",
+		"P2 1 1 255 10":  "*pbm.GrayImage",
+		"P5 1 1 255 \n":  "*pbm.GrayImage",
+		"P2 1 1 1023 10": "*pbm.GrayImage",
+		"P3 1 1 7 1 2 3": "*pbm.ColorImage",
+		"P6 1 1 255 abc": "*pbm.ColorImage",
+		"P7\nWIDTH 1\nHEIGHT 1\nDEPTH 1\nMAXVAL 1\nTUPLTYPE BLACKANDWHITE\nENDHDR\n\x00": "*pbm.Image",
+	}
+	for input, want := range inputs {
+		image, err := NewNetpbmFromString(input)
+		if err != nil {
+			t.Fatalf("unexpected parse error for '%s': %s", input, err)
+		}
+		if got := fmt.Sprintf("%T", image); got != want {
+			t.Fatalf("unexpected type for '%s', want %s, got %s", input, want, got)
+		}
+	}
+}
+
+func TestNetpbm_invalid(t *testing.T) {
+	for _, input := range []string{"", "P", "P9 1 1 1", "P2 1 1 1"} {
+		if _, err := NewNetpbmFromString(input); err == nil {
+			t.Fatalf("should have fail: invalid input '%s'", input)
+		}
+	}
+}
+
+func TestNetpbm_doesNotExist(t *testing.T) {
+	if _, err := NewNetpbmFromFile("/dos/not/exist"); err == nil {
+		t.Fatalf("should have fail, path does not exist")
+	}
+}
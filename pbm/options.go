@@ -0,0 +1,114 @@
+// Copyright 2023 Xavier MARCELET. All rights reserved.
+// Use of this source code is governed by Apache
+// license that can be found in the LICENSE file.
+
+package pbm
+
+// Algorithm - method used to rotate images by arbitrary angles
+type Algorithm int
+
+const (
+	// AlgorithmRotator maps pixels through rotation matrix, see Rotator
+	AlgorithmRotator Algorithm = iota
+	// AlgorithmShear decomposes rotation in three shears, see Shearer
+	AlgorithmShear
+)
+
+// options - settings of image transformations
+type options struct {
+	resize    bool      // grow result image to hold every transformed pixel
+	forward   bool      // map source pixels to destination instead of sampling source
+	algorithm Algorithm // rotation method for angles that are not multiple of 90 degrees
+	workers   int       // number of concurrent workers, GOMAXPROCS when zero
+	// center of rotation of given image, nil for geometric center, last value
+	// is false when center can't be computed
+	center func(img Sizer) (float64, float64, bool)
+}
+
+// Option - setting of image transformations, see With* functions
+type Option func(*options)
+
+// newOptions applies given settings on top of default ones
+func newOptions(opts []Option) *options {
+	result := &options{}
+	for _, cOpt := range opts {
+		cOpt(result)
+	}
+	return result
+}
+
+// WithResize makes result image large enough to hold all transformed pixels
+// instead of keeping source image size
+func WithResize() Option {
+	return func(o *options) {
+		o.resize = true
+	}
+}
+
+// WithForwardMapping projects each source pixel to its destination
+//
+// This was the original rotation method, it leaves holes and duplicates
+// pixels for angles that are not multiple of 90 degrees. By default, each
+// destination pixel is sampled from source through inverse transformation.
+func WithForwardMapping() Option {
+	return func(o *options) {
+		o.forward = true
+	}
+}
+
+// WithAlgorithm selects rotation method for angles that are not multiple of
+// 90 degrees, AlgorithmRotator by default
+func WithAlgorithm(algorithm Algorithm) Option {
+	return func(o *options) {
+		o.algorithm = algorithm
+	}
+}
+
+// WithWorkers processes result rows with given number of concurrent
+// workers, GOMAXPROCS by default
+//
+// Result doesn't depend on number of workers, a single worker processes
+// whole image from calling goroutine. Forward mapping is never concurrent.
+func WithWorkers(workers int) Option {
+	return func(o *options) {
+		o.workers = workers
+	}
+}
+
+// WithCenter rotates images around given pixel coordinates
+//
+// Center of rotation only matters when result keeps source image frame: it
+// stays at the same position in result image. Right angles are no longer
+// exact permutations and keep source image size.
+func WithCenter(x, y float64) Option {
+	return func(o *options) {
+		o.center = func(Sizer) (float64, float64, bool) {
+			return x, y, true
+		}
+	}
+}
+
+// WithAnchor rotates images around given named position, see WithCenter
+func WithAnchor(anchor Anchor) Option {
+	return func(o *options) {
+		o.center = func(img Sizer) (float64, float64, bool) {
+			x, y := anchor.Point(img)
+			return x, y, true
+		}
+	}
+}
+
+// WithCentroid rotates images around the centroid of their black pixels,
+// non-white for gray and color images, see WithCenter
+//
+// Geometric center is used for blank images.
+func WithCentroid() Option {
+	return func(o *options) {
+		o.center = func(img Sizer) (float64, float64, bool) {
+			if located, ok := img.(centroider); ok {
+				return located.centroid()
+			}
+			return 0, 0, false
+		}
+	}
+}
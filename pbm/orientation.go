@@ -0,0 +1,193 @@
+// Copyright 2023 Xavier MARCELET. All rights reserved.
+// Use of this source code is governed by Apache
+// license that can be found in the LICENSE file.
+
+package pbm
+
+import (
+	"fmt"
+)
+
+// Orientation - lossless flip or right-angle rotation, numbered as EXIF
+// orientation tag values
+//
+// Each value names the operation that displays correctly an image stored with
+// this EXIF orientation.
+type Orientation int
+
+const (
+	OrientationNormal         Orientation = 1
+	OrientationFlipHorizontal Orientation = 2
+	OrientationRotate180      Orientation = 3
+	OrientationFlipVertical   Orientation = 4
+	OrientationTranspose      Orientation = 5
+	OrientationRotate90       Orientation = 6
+	OrientationTransverse     Orientation = 7
+	OrientationRotate270      Orientation = 8
+)
+
+// turnOrientations - orientation matching a number of clockwise quarter turns
+var turnOrientations = [4]Orientation{
+	OrientationNormal,
+	OrientationRotate90,
+	OrientationRotate180,
+	OrientationRotate270,
+}
+
+// orientPixels returns a copy of given pixels with given orientation applied
+//
+// Operation is an exact permutation of pixels, width and height are swapped
+// for transpositions and quarter turns. Bands of source rows are processed by
+// given number of workers, each pixel having its own destination.
+func orientPixels[T any](data []T, width, height int, orientation Orientation, workers int) []T {
+	result := make([]T, len(data))
+	parallelRows(height, workers, func(top, bottom int) {
+		orientRows(result, data, width, height, orientation, top, bottom)
+	})
+	return result
+}
+
+// orientRows writes source rows from top to bottom at their oriented
+// position in result, see orientPixels
+//
+//  1. row length of result image
+func orientRows[T any](result, data []T, width, height int, orientation Orientation, top, bottom int) {
+	// 1.
+	destWidth := width
+	if orientation.swapsSize() {
+		destWidth = height
+	}
+	for y := top; y < bottom; y++ {
+		for x := 0; x < width; x++ {
+			destX, destY := orientPoint(x, y, width, height, orientation)
+			result[destX+destY*destWidth] = data[x+y*width]
+		}
+	}
+}
+
+// orientPoint gives coordinates of pixel after given orientation of an image
+// of given size, coordinates may be out of image
+func orientPoint(x, y, width, height int, orientation Orientation) (int, int) {
+	switch orientation {
+	case OrientationFlipHorizontal:
+		return width - 1 - x, y
+	case OrientationRotate180:
+		return width - 1 - x, height - 1 - y
+	case OrientationFlipVertical:
+		return x, height - 1 - y
+	case OrientationTranspose:
+		return y, x
+	case OrientationRotate90:
+		return height - 1 - y, x
+	case OrientationTransverse:
+		return height - 1 - y, width - 1 - x
+	case OrientationRotate270:
+		return y, width - 1 - x
+	}
+	return x, y
+}
+
+// swapsSize tells if orientation exchanges width and height
+func (o Orientation) swapsSize() bool {
+	return o >= OrientationTranspose
+}
+
+// inverse gives orientation that restores original image, quarter turns are
+// the only orientations which are not their own inverse
+func (o Orientation) inverse() Orientation {
+	switch o {
+	case OrientationRotate90:
+		return OrientationRotate270
+	case OrientationRotate270:
+		return OrientationRotate90
+	}
+	return o
+}
+
+// check validates orientation value
+func (o Orientation) check() error {
+	if o < OrientationNormal || o > OrientationRotate270 {
+		return fmt.Errorf("invalid orientation '%d', expecting 1 to 8", int(o))
+	}
+	return nil
+}
+
+// Orient applies given orientation to image, fails for values out of 1 to 8,
+// only WithWorkers option applies
+func (i *Image) Orient(orientation Orientation, opts ...Option) error {
+	if err := orientation.check(); err != nil {
+		return err
+	}
+	*i = *orientBits(i, orientation, newOptions(opts).workers)
+	return nil
+}
+
+// Orient applies given orientation to image, fails for values out of 1 to 8,
+// only WithWorkers option applies
+func (g *GrayImage) Orient(orientation Orientation, opts ...Option) error {
+	if err := orientation.check(); err != nil {
+		return err
+	}
+	workers := newOptions(opts).workers
+	g.data = orientPixels(g.data, g.width, g.height, orientation, workers)
+	if g.HasAlpha() {
+		g.alpha = orientPixels(g.alpha, g.width, g.height, orientation, workers)
+	}
+	if orientation.swapsSize() {
+		g.width, g.height = g.height, g.width
+	}
+	return nil
+}
+
+// Orient applies given orientation to image, fails for values out of 1 to 8,
+// only WithWorkers option applies
+func (c *ColorImage) Orient(orientation Orientation, opts ...Option) error {
+	if err := orientation.check(); err != nil {
+		return err
+	}
+	workers := newOptions(opts).workers
+	c.data = orientPixels(c.data, c.width, c.height, orientation, workers)
+	if c.HasAlpha() {
+		c.alpha = orientPixels(c.alpha, c.width, c.height, orientation, workers)
+	}
+	if orientation.swapsSize() {
+		c.width, c.height = c.height, c.width
+	}
+	return nil
+}
+
+// FlipHorizontal mirrors image left to right
+func (i *Image) FlipHorizontal() { _ = i.Orient(OrientationFlipHorizontal) }
+
+// FlipVertical mirrors image top to bottom
+func (i *Image) FlipVertical() { _ = i.Orient(OrientationFlipVertical) }
+
+// Transpose mirrors image along its top-left to bottom-right diagonal
+func (i *Image) Transpose() { _ = i.Orient(OrientationTranspose) }
+
+// Transverse mirrors image along its top-right to bottom-left diagonal
+func (i *Image) Transverse() { _ = i.Orient(OrientationTransverse) }
+
+// FlipHorizontal mirrors image left to right
+func (g *GrayImage) FlipHorizontal() { _ = g.Orient(OrientationFlipHorizontal) }
+
+// FlipVertical mirrors image top to bottom
+func (g *GrayImage) FlipVertical() { _ = g.Orient(OrientationFlipVertical) }
+
+// Transpose mirrors image along its top-left to bottom-right diagonal
+func (g *GrayImage) Transpose() { _ = g.Orient(OrientationTranspose) }
+
+// Transverse mirrors image along its top-right to bottom-left diagonal
+func (g *GrayImage) Transverse() { _ = g.Orient(OrientationTransverse) }
+
+// FlipHorizontal mirrors image left to right
+func (c *ColorImage) FlipHorizontal() { _ = c.Orient(OrientationFlipHorizontal) }
+
+// FlipVertical mirrors image top to bottom
+func (c *ColorImage) FlipVertical() { _ = c.Orient(OrientationFlipVertical) }
+
+// Transpose mirrors image along its top-left to bottom-right diagonal
+func (c *ColorImage) Transpose() { _ = c.Orient(OrientationTranspose) }
+
+// Transverse mirrors image along its top-right to bottom-left diagonal
+func (c *ColorImage) Transverse() { _ = c.Orient(OrientationTransverse) }
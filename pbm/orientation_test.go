@@ -0,0 +1,84 @@
+// Copyright 2023 Xavier MARCELET. All rights reserved.
+// Use of this source code is governed by Apache
+// license that can be found in the LICENSE file.
+
+package pbm
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestOrient(t *testing.T) {
+	in := `P1
+3 2
+100
+011
+`
+	orient := func(orientation Orientation) func(*Image) error {
+		return func(img *Image) error { return img.Orient(orientation) }
+	}
+	checkOperation(t, in, in, orient(OrientationNormal))
+	checkOperation(t, in, "P1\n3 2\n001\n110\n", orient(OrientationFlipHorizontal))
+	checkOperation(t, in, "P1\n3 2\n110\n001\n", orient(OrientationRotate180))
+	checkOperation(t, in, "P1\n3 2\n011\n100\n", orient(OrientationFlipVertical))
+	checkOperation(t, in, "P1\n2 3\n10\n01\n01\n", orient(OrientationTranspose))
+	checkOperation(t, in, "P1\n2 3\n01\n10\n10\n", orient(OrientationRotate90))
+	checkOperation(t, in, "P1\n2 3\n10\n10\n01\n", orient(OrientationTransverse))
+	checkOperation(t, in, "P1\n2 3\n01\n01\n10\n", orient(OrientationRotate270))
+
+	img, err := NewImageFromString(in)
+	if err != nil {
+		t.Fatalf("unexpected parse error: %s", err)
+	}
+	if err := img.Orient(9); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestOrient_compose(t *testing.T) {
+	in := `P1
+3 2
+100
+011
+`
+	encode := func(operations ...func(*Image)) string {
+		img, err := NewImageFromString(in)
+		if err != nil {
+			t.Fatalf("unexpected parse error: %s", err)
+		}
+		for _, cOperation := range operations {
+			cOperation(img)
+		}
+		writer := bytes.Buffer{}
+		if err := img.EncodeASCII(&writer); err != nil {
+			t.Fatalf("unexpected encode error: %s", err)
+		}
+		return writer.String()
+	}
+	rotate := func(angle float64) func(*Image) {
+		return func(img *Image) { img.Rotate(angle) }
+	}
+
+	// flips compose with right-angle rotations
+	if encode(rotate(90), (*Image).FlipHorizontal) != encode((*Image).Transpose) {
+		t.Fatalf("quarter turn and horizontal flip must match transpose")
+	}
+	if encode(rotate(90), (*Image).FlipVertical) != encode((*Image).Transverse) {
+		t.Fatalf("quarter turn and vertical flip must match transverse")
+	}
+	if encode((*Image).Transpose, (*Image).Transverse) != encode(rotate(180)) {
+		t.Fatalf("transpose and transverse must match half turn")
+	}
+	if encode((*Image).FlipHorizontal, (*Image).FlipHorizontal) != in {
+		t.Fatalf("flip must be its own inverse")
+	}
+}
+
+func TestOrientGray_alpha(t *testing.T) {
+	image := &GrayImage{2, 1, 255, []uint16{1, 2}, []uint16{0, 255}}
+	image.FlipHorizontal()
+	if image.data[0] != 2 || image.data[1] != 1 || image.alpha[0] != 255 || image.alpha[1] != 0 {
+		t.Fatalf("unexpected pixels %v, alpha %v", image.data, image.alpha)
+	}
+}
@@ -0,0 +1,318 @@
+// Copyright 2023 Xavier MARCELET. All rights reserved.
+// Use of this source code is governed by Apache
+// license that can be found in the LICENSE file.
+
+package pbm
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// PAMMagicP7 is the special magic header of PAM arbitrary map format
+// see https://netpbm.sourceforge.net/doc/pam.html
+const PAMMagicP7 string = "P7"
+
+// Supported PAM tuple types
+const (
+	PAMBlackAndWhite      string = "BLACKANDWHITE"
+	PAMBlackAndWhiteAlpha string = "BLACKANDWHITE_ALPHA"
+	PAMGrayscale          string = "GRAYSCALE"
+	PAMGrayscaleAlpha     string = "GRAYSCALE_ALPHA"
+	PAMRGB                string = "RGB"
+	PAMRGBAlpha           string = "RGB_ALPHA"
+)
+
+// pamDepths gives expected number of samples per tuple for each tuple type
+var pamDepths = map[string]int{
+	PAMBlackAndWhite:      1,
+	PAMBlackAndWhiteAlpha: 2,
+	PAMGrayscale:          1,
+	PAMGrayscaleAlpha:     2,
+	PAMRGB:                3,
+	PAMRGBAlpha:           4,
+}
+
+// pamHeader - header fields of a PAM image
+type pamHeader struct {
+	width    int
+	height   int
+	depth    int
+	maxval   int
+	tupltype string
+}
+
+// Creates image object from given PAM string
+//
+// Returned type depends on tuple type: *Image for BLACKANDWHITE, *GrayImage
+// for GRAYSCALE and BLACKANDWHITE_ALPHA, *ColorImage for RGB. Alpha variants
+// set image's alpha channel.
+func NewPAMFromString(value string) (Netpbm, error) {
+	return parsePAM(strings.NewReader(value))
+}
+
+// Creates image object from given PAM file path, see NewPAMFromString
+func NewPAMFromFile(path string) (Netpbm, error) {
+	var image Netpbm
+	err := decodeFromFile(path, func(stream io.Reader) error {
+		var err error
+		image, err = parsePAM(stream)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return image, nil
+}
+
+// extract next header line from buffer, ignoring comments and empty lines
+//
+//  1. scanner reads more data before calling us again when no token is
+//     returned, skipped lines must be consumed in the same call
+func nextLine(data []byte, atEOF bool) (int, []byte, error) {
+	advance := 0
+	// 1.
+	for {
+		count, line, err := bufio.ScanLines(data[advance:], atEOF)
+		if err != nil || count == 0 {
+			return advance, nil, err
+		}
+		advance += count
+		line = bytes.TrimSpace(line)
+		if len(line) != 0 && line[0] != '#' {
+			return advance, line, nil
+		}
+	}
+}
+
+func parsePAM(stream io.Reader) (Netpbm, error) {
+	var image Netpbm
+	err := parseSingle(stream, func(d *Decoder, magic string) error {
+		var err error
+		image, err = decodePAM(d)
+		return err
+	}, PAMMagicP7)
+	if err != nil {
+		return nil, err
+	}
+	return image, nil
+}
+
+func decodePAM(d *Decoder) (Netpbm, error) {
+	header, err := decodePAMHeader(d)
+	if err != nil {
+		return nil, err
+	}
+
+	samples := make([]uint16, header.width*header.height*header.depth)
+	if err := d.binarySamples(samples, header.maxval); err != nil {
+		return nil, err
+	}
+	return header.image(samples), nil
+}
+
+// decodePAMHeader reads header lines until ENDHDR
+//
+//  1. tuple type may be given on several lines, values are joined by a space
+//  2. dimensions and depth have no default, unlike maxval zero they may be
+//     valid values so that presence of their line is checked
+func decodePAMHeader(d *Decoder) (*pamHeader, error) {
+	header := &pamHeader{}
+	tupltypes := []string{}
+	keys := map[string]bool{}
+	d.tokenizer.split = nextLine
+	for d.scanner.Scan() {
+		fields := strings.Fields(d.scanner.Text())
+		key, value := fields[0], strings.Join(fields[1:], " ")
+		keys[key] = true
+		switch key {
+		case "ENDHDR":
+			// 1.
+			header.tupltype = strings.Join(tupltypes, " ")
+			// 2.
+			for _, cKey := range []string{"WIDTH", "HEIGHT", "DEPTH"} {
+				if !keys[cKey] {
+					return nil, fmt.Errorf("invalid header, missing %s line", cKey)
+				}
+			}
+			return header, header.check()
+		case "TUPLTYPE":
+			tupltypes = append(tupltypes, value)
+			continue
+		}
+
+		number, err := strconv.Atoi(value)
+		if err != nil || number < 0 {
+			return nil, fmt.Errorf("invalid header value '%s' for %s, expecting number", value, key)
+		}
+		switch key {
+		case "WIDTH":
+			header.width = number
+		case "HEIGHT":
+			header.height = number
+		case "DEPTH":
+			header.depth = number
+		case "MAXVAL":
+			header.maxval = number
+		default:
+			return nil, fmt.Errorf("invalid header line '%s'", d.scanner.Text())
+		}
+	}
+	if err := d.scanner.Err(); err != nil {
+		return nil, fmt.Errorf("invalid input: %s", err)
+	}
+	return nil, fmt.Errorf("invalid format, expected header end ENDHDR")
+}
+
+// check validates header values and sets default tuple type
+//
+//  1. tuple type is optional, guess it from depth as netpbm does
+func (h *pamHeader) check() error {
+	if h.maxval == 0 || h.maxval > PGMMaxval {
+		return fmt.Errorf("invalid maxval '%d', expecting value between 1 and %d", h.maxval, PGMMaxval)
+	}
+
+	// 1.
+	if h.tupltype == "" {
+		for _, cType := range []string{PAMGrayscale, PAMGrayscaleAlpha, PAMRGB, PAMRGBAlpha} {
+			if pamDepths[cType] == h.depth {
+				h.tupltype = cType
+			}
+		}
+	}
+
+	depth, ok := pamDepths[h.tupltype]
+	if !ok {
+		return fmt.Errorf("unsupported tuple type '%s'", h.tupltype)
+	}
+	if depth != h.depth {
+		return fmt.Errorf("invalid depth '%d' for tuple type %s, expecting %d", h.depth, h.tupltype, depth)
+	}
+	if h.maxval != 1 && (h.tupltype == PAMBlackAndWhite || h.tupltype == PAMBlackAndWhiteAlpha) {
+		return fmt.Errorf("invalid maxval '%d' for tuple type %s, expecting 1", h.maxval, h.tupltype)
+	}
+	return nil
+}
+
+// image builds image object matching tuple type from interleaved samples
+//
+//  1. unlike PBM, BLACKANDWHITE sample 0 is black
+func (h *pamHeader) image(samples []uint16) Netpbm {
+	switch h.tupltype {
+	case PAMBlackAndWhite:
+		image := newImage(h.width, h.height)
+		for cIdx, cSample := range samples {
+			// 1.
+			image.set(cIdx%h.width, cIdx/h.width, cSample == 0)
+		}
+		return image
+	case PAMGrayscale, PAMGrayscaleAlpha, PAMBlackAndWhiteAlpha:
+		image := &GrayImage{width: h.width, height: h.height, maxval: h.maxval}
+		image.data = channel(samples, h.depth, 0)
+		if h.depth == 2 {
+			image.alpha = channel(samples, h.depth, 1)
+		}
+		return image
+	default:
+		image := &ColorImage{width: h.width, height: h.height, maxval: h.maxval}
+		image.data = make([]rgb, h.width*h.height)
+		for cIdx := range image.data {
+			image.data[cIdx] = rgb{samples[cIdx*h.depth], samples[cIdx*h.depth+1], samples[cIdx*h.depth+2]}
+		}
+		if h.depth == 4 {
+			image.alpha = channel(samples, h.depth, 3)
+		}
+		return image
+	}
+}
+
+// channel extracts samples of given index from interleaved tuples
+func channel(samples []uint16, depth int, index int) []uint16 {
+	result := make([]uint16, len(samples)/depth)
+	for cIdx := range result {
+		result[cIdx] = samples[cIdx*depth+index]
+	}
+	return result
+}
+
+// interleave builds tuples from given sample and alpha channels
+func interleave(samples []uint16, alpha []uint16) []uint16 {
+	result := make([]uint16, 0, len(samples)*2)
+	for cIdx, cSample := range samples {
+		result = append(result, cSample, alpha[cIdx])
+	}
+	return result
+}
+
+func (h *pamHeader) encode(stream io.Writer, samples []uint16) error {
+	_, err := fmt.Fprintf(stream, "%s\nWIDTH %d\nHEIGHT %d\nDEPTH %d\nMAXVAL %d\nTUPLTYPE %s\nENDHDR\n",
+		PAMMagicP7, h.width, h.height, h.depth, h.maxval, h.tupltype)
+	if err != nil {
+		return err
+	}
+	return encodeBinarySamples(stream, samples, h.maxval)
+}
+
+// Serialize image into file in PAM representation
+func (i *Image) EncodePAMToFile(path string) error {
+	return encodeToFile(path, i.EncodePAM)
+}
+
+// Serialize image into stream in PAM representation, with BLACKANDWHITE tuple type
+func (i *Image) EncodePAM(stream io.Writer) error {
+	header := &pamHeader{i.width, i.height, 1, 1, PAMBlackAndWhite}
+	samples := make([]uint16, i.width*i.height)
+	for cIdx := range samples {
+		if !i.at(cIdx%i.width, cIdx/i.width) {
+			samples[cIdx] = 1
+		}
+	}
+	return header.encode(stream, samples)
+}
+
+// Serialize image into file in PAM representation
+func (g *GrayImage) EncodePAMToFile(path string) error {
+	return encodeToFile(path, g.EncodePAM)
+}
+
+// Serialize image into stream in PAM representation, with GRAYSCALE tuple
+// type or GRAYSCALE_ALPHA when image has an alpha channel
+func (g *GrayImage) EncodePAM(stream io.Writer) error {
+	header := &pamHeader{g.width, g.height, 1, g.maxval, PAMGrayscale}
+	if !g.HasAlpha() {
+		return header.encode(stream, g.data)
+	}
+
+	header.depth = 2
+	header.tupltype = PAMGrayscaleAlpha
+	if g.maxval == 1 {
+		header.tupltype = PAMBlackAndWhiteAlpha
+	}
+	return header.encode(stream, interleave(g.data, g.alpha))
+}
+
+// Serialize image into file in PAM representation
+func (c *ColorImage) EncodePAMToFile(path string) error {
+	return encodeToFile(path, c.EncodePAM)
+}
+
+// Serialize image into stream in PAM representation, with RGB tuple type or
+// RGB_ALPHA when image has an alpha channel
+func (c *ColorImage) EncodePAM(stream io.Writer) error {
+	header := &pamHeader{c.width, c.height, 3, c.maxval, PAMRGB}
+	if !c.HasAlpha() {
+		return header.encode(stream, c.samples())
+	}
+
+	header.depth = 4
+	header.tupltype = PAMRGBAlpha
+	samples := make([]uint16, 0, len(c.data)*4)
+	for cIdx, cPixel := range c.data {
+		samples = append(samples, cPixel.r, cPixel.g, cPixel.b, c.alpha[cIdx])
+	}
+	return header.encode(stream, samples)
+}
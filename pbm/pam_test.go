@@ -0,0 +1,176 @@
+// Copyright 2023 Xavier MARCELET. All rights reserved.
+// Use of this source code is governed by Apache
+// license that can be found in the LICENSE file.
+
+package pbm
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPAM_blackAndWhite(t *testing.T) {
+	// BLACKANDWHITE sample 0 is black
+	input := "P7\nWIDTH 2\nHEIGHT 2\nDEPTH 1\nMAXVAL 1\nTUPLTYPE BLACKANDWHITE\nENDHDR\n\x00\x01\x01\x00"
+	image, err := NewPAMFromString(input)
+	if err != nil {
+		t.Fatalf("unexpected parse error: %s", err)
+	}
+	bitmap, ok := image.(*Image)
+	if !ok {
+		t.Fatalf("unexpected image type %T", image)
+	}
+	expect(t, bitmap, nil, 2, 2, "1001")
+
+	writer := bytes.Buffer{}
+	if err := bitmap.EncodePAM(&writer); err != nil {
+		t.Fatalf("unexpected serialization error: %s", err)
+	}
+	if writer.String() != input {
+		t.Fatalf("unexpected serialization output: %v, want %v", writer.Bytes(), []byte(input))
+	}
+}
+
+func TestPAM_grayscaleAlpha(t *testing.T) {
+	input := `P7
+# grandma's transparent memory
+WIDTH 2
+HEIGHT 1
+DEPTH 2
+MAXVAL 65535
+TUPLTYPE GRAYSCALE_ALPHA
+ENDHDR
+` + "\x01\x02\xff\xff\x00\x10\x00\x00"
+	image, err := NewPAMFromString(input)
+	if err != nil {
+		t.Fatalf("unexpected parse error: %s", err)
+	}
+	gray, ok := image.(*GrayImage)
+	if !ok {
+		t.Fatalf("unexpected image type %T", image)
+	}
+	expectGray(t, gray, nil, 2, 1, 65535, []uint16{258, 16})
+	if !gray.HasAlpha() || gray.alpha[0] != 65535 || gray.alpha[1] != 0 {
+		t.Fatalf("unexpected alpha channel: %v", gray.alpha)
+	}
+}
+
+func TestPAM_blackAndWhiteAlpha(t *testing.T) {
+	input := "P7\nWIDTH 2\nHEIGHT 1\nDEPTH 2\nMAXVAL 1\nTUPLTYPE BLACKANDWHITE_ALPHA\nENDHDR\n\x00\x01\x01\x00"
+	image, err := NewPAMFromString(input)
+	if err != nil {
+		t.Fatalf("unexpected parse error: %s", err)
+	}
+	writer := bytes.Buffer{}
+	if err := image.EncodePAM(&writer); err != nil {
+		t.Fatalf("unexpected serialization error: %s", err)
+	}
+	if writer.String() != input {
+		t.Fatalf("unexpected serialization output: %v, want %v", writer.Bytes(), []byte(input))
+	}
+}
+
+func TestPAM_rgbAlpha(t *testing.T) {
+	input := "P7\nWIDTH 1\nHEIGHT 2\nDEPTH 4\nMAXVAL 255\nTUPLTYPE RGB_ALPHA\nENDHDR\n\xff\x00\x00\xff\x00\x00\xff\x80"
+	image, err := NewPAMFromString(input)
+	if err != nil {
+		t.Fatalf("unexpected parse error: %s", err)
+	}
+	color, ok := image.(*ColorImage)
+	if !ok {
+		t.Fatalf("unexpected image type %T", image)
+	}
+	expectColor(t, color, nil, 1, 2, 255, []rgb{{255, 0, 0}, {0, 0, 255}})
+
+	writer := bytes.Buffer{}
+	if err := color.EncodePAM(&writer); err != nil {
+		t.Fatalf("unexpected serialization error: %s", err)
+	}
+	if writer.String() != input {
+		t.Fatalf("unexpected serialization output: %v, want %v", writer.Bytes(), []byte(input))
+	}
+}
+
+func TestPAM_defaultTupleType(t *testing.T) {
+	input := "P7\nWIDTH 1\nHEIGHT 1\nDEPTH 3\nMAXVAL 255\nENDHDR\n\x01\x02\x03"
+	image, err := NewPAMFromString(input)
+	if err != nil {
+		t.Fatalf("unexpected parse error: %s", err)
+	}
+	if _, ok := image.(*ColorImage); !ok {
+		t.Fatalf("unexpected image type %T", image)
+	}
+}
+
+func TestPAM_invalid(t *testing.T) {
+	inputs := []string{
+		"P6\nWIDTH 1\nHEIGHT 1\nDEPTH 1\nMAXVAL 255\nENDHDR\n\x00",
+		"P7\nWIDTH 1\nHEIGHT 1\nDEPTH 1\nMAXVAL 255\n\x00",
+		"P7\nWIDTH x\nHEIGHT 1\nDEPTH 1\nMAXVAL 255\nENDHDR\n\x00",
+		"P7\nWIDTH 1\nHEIGHT 1\nDEPTH 1\nMAXVAL 0\nENDHDR\n\x00",
+		"P7\nWIDTH 1\nHEIGHT 1\nDEPTH 1\nMAXVAL 255\nCOLORS 1\nENDHDR\n\x00",
+		"P7\nWIDTH 1\nHEIGHT 1\nDEPTH 1\nMAXVAL 255\nTUPLTYPE CMYK\nENDHDR\n\x00",
+		"P7\nWIDTH 1\nHEIGHT 1\nDEPTH 2\nMAXVAL 255\nTUPLTYPE RGB\nENDHDR\n\x00\x00",
+		"P7\nWIDTH 1\nHEIGHT 1\nDEPTH 1\nMAXVAL 255\nTUPLTYPE BLACKANDWHITE\nENDHDR\n\x00",
+		"P7\nWIDTH 1\nHEIGHT 1\nDEPTH 1\nMAXVAL 255\nENDHDR\n\x00\x00",
+		// missing dimensions or depth
+		"P7\nHEIGHT 1\nDEPTH 1\nMAXVAL 255\nENDHDR\n",
+		"P7\nWIDTH 1\nDEPTH 1\nMAXVAL 255\nENDHDR\n",
+		"P7\nWIDTH 1\nHEIGHT 1\nMAXVAL 255\nTUPLTYPE GRAYSCALE\nENDHDR\n",
+	}
+	for _, input := range inputs {
+		if _, err := NewPAMFromString(input); err == nil {
+			t.Fatalf("should have fail: invalid input '%v'", []byte(input))
+		}
+	}
+}
+
+func TestPAM_rotateTransparent(t *testing.T) {
+	// uncovered corners are transparent
+	input := "P7\nWIDTH 4\nHEIGHT 4\nDEPTH 2\nMAXVAL 255\nTUPLTYPE GRAYSCALE_ALPHA\nENDHDR\n" +
+		strings.Repeat("\x00\xff", 16)
+	image, err := NewPAMFromString(input)
+	if err != nil {
+		t.Fatalf("unexpected parse error: %s", err)
+	}
+	image.Rotate(45)
+	gray := image.(*GrayImage)
+	for _, cIdx := range []int{0, 3, 12, 15} {
+		if gray.alpha[cIdx] != 0 {
+			t.Fatalf("unexpected alpha channel: %v", gray.alpha)
+		}
+	}
+	if gray.alpha[6] != 255 || gray.alpha[9] != 255 {
+		t.Fatalf("unexpected alpha channel: %v", gray.alpha)
+	}
+}
+
+func TestPAM_file(t *testing.T) {
+	image := ColorImage{1, 1, 255, []rgb{{1, 2, 3}}, []uint16{4}}
+	dir, err := os.MkdirTemp("", "test")
+	if err != nil {
+		t.Fatalf("could not create temp directory")
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "output")
+	if err := image.EncodePAMToFile(path); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	decoded, err := NewNetpbmFromFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	color := decoded.(*ColorImage)
+	expectColor(t, color, nil, 1, 1, 255, image.data)
+	if color.alpha[0] != 4 {
+		t.Fatalf("unexpected alpha channel: %v", color.alpha)
+	}
+
+	if _, err := NewPAMFromFile("/dos/not/exist"); err == nil {
+		t.Fatalf("should have fail, path does not exist")
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2023 Xavier MARCELET. All rights reserved.
+// Use of this source code is governed by Apache
+// license that can be found in the LICENSE file.
+
+package pbm
+
+import (
+	"runtime"
+	"sync"
+)
+
+// bandsPerWorker - number of row bands per worker, more bands than workers
+// balance load when some bands are faster, blank ones for instance
+const bandsPerWorker = 4
+
+// parallelRows calls fn on consecutive bands of rows covering [0, height),
+// concurrently on given number of workers
+//
+// Zero workers means GOMAXPROCS, a single worker calls fn once on all rows
+// from calling goroutine. Bands must not write to shared data, so that result
+// doesn't depend on number of workers.
+//
+//  1. workers pull first row of next band until all bands are processed
+func parallelRows(height, workers int, fn func(top, bottom int)) {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > height {
+		workers = height
+	}
+	if workers <= 1 {
+		fn(0, height)
+		return
+	}
+
+	var (
+		bands      = workers * bandsPerWorker
+		bandHeight = (height + bands - 1) / bands
+		tops       = make(chan int)
+		group      sync.WaitGroup
+	)
+	for cWorker := 0; cWorker < workers; cWorker++ {
+		group.Add(1)
+		go func() {
+			defer group.Done()
+			// 1.
+			for top := range tops {
+				bottom := top + bandHeight
+				if bottom > height {
+					bottom = height
+				}
+				fn(top, bottom)
+			}
+		}()
+	}
+	for top := 0; top < height; top += bandHeight {
+		tops <- top
+	}
+	close(tops)
+	group.Wait()
+}
@@ -0,0 +1,91 @@
+// Copyright 2023 Xavier MARCELET. All rights reserved.
+// Use of this source code is governed by Apache
+// license that can be found in the LICENSE file.
+
+package pbm
+
+import (
+	"bytes"
+	"image"
+	"sync"
+	"testing"
+)
+
+func TestParallelRows_coverage(t *testing.T) {
+	for _, cHeight := range []int{0, 1, 5, 100} {
+		for _, cWorkers := range []int{0, 1, 3, 200} {
+			var (
+				lock  sync.Mutex
+				count = make([]int, cHeight)
+			)
+			parallelRows(cHeight, cWorkers, func(top, bottom int) {
+				lock.Lock()
+				defer lock.Unlock()
+				for y := top; y < bottom; y++ {
+					count[y]++
+				}
+			})
+			for y, cCount := range count {
+				if cCount != 1 {
+					t.Fatalf("row %d of %d processed %d times by %d workers", y, cHeight, cCount, cWorkers)
+				}
+			}
+		}
+	}
+}
+
+func TestParallel_identical(t *testing.T) {
+	pixels := randomPixels(150, 90)
+	encode := func(image Netpbm) string {
+		writer := bytes.Buffer{}
+		if err := image.EncodePAM(&writer); err != nil {
+			t.Fatalf("unexpected encode error: %s", err)
+		}
+		return writer.String()
+	}
+	for _, cAngle := range []float64{90, 180, 270, 12.5, -30} {
+		for _, cAlgorithm := range []Algorithm{AlgorithmRotator, AlgorithmShear} {
+			results := []string{}
+			for _, cWorkers := range []int{1, 4, 0} {
+				opts := []Option{WithAlgorithm(cAlgorithm), WithResize(), WithWorkers(cWorkers)}
+				bitmap := newImageFromPixels(150, 90, pixels)
+				bitmap.Rotate(cAngle, opts...)
+				gray := newImageFromPixels(150, 90, pixels).ToGray(255)
+				gray.Rotate(cAngle, opts...)
+				results = append(results, encode(bitmap)+encode(gray))
+			}
+			if results[0] != results[1] || results[0] != results[2] {
+				t.Fatalf("concurrent rotation of %v degrees differs from sequential one", cAngle)
+			}
+		}
+	}
+}
+
+func TestParallel_options(t *testing.T) {
+	pixels := randomPixels(150, 90)
+	encode := func(image Netpbm) string {
+		writer := bytes.Buffer{}
+		if err := image.EncodePAM(&writer); err != nil {
+			t.Fatalf("unexpected encode error: %s", err)
+		}
+		return writer.String()
+	}
+	results := []string{}
+	for _, cWorkers := range []int{1, 4} {
+		result := ""
+		workers := WithWorkers(cWorkers)
+		for _, cImage := range []Netpbm{newImageFromPixels(150, 90, pixels), newImageFromPixels(150, 90, pixels).ToGray(255)} {
+			_ = cImage.Orient(OrientationTransverse, workers)
+			for _, cFilter := range []Filter{FilterNearest, FilterBox, FilterEPX} {
+				_ = cImage.Scale(cImage.Width()*5/4, cImage.Height()*9/4, cFilter, workers)
+			}
+			result += encode(cImage)
+		}
+		bitmap := newImageFromPixels(150, 90, pixels).Canvas(200, 50, AnchorBottomRight, Black, workers)
+		result += encode(bitmap.Pad(3, 2, 1, 0, White, workers).Crop(image.Rect(5, 5, 100, 30), workers))
+		results = append(results, result)
+	}
+	if results[0] != results[1] {
+		t.Fatalf("concurrent operations differ from sequential ones")
+	}
+}
@@ -6,15 +6,21 @@ package pbm
 
 import (
 	"bufio"
+	"encoding/binary"
 	"fmt"
 	"io"
 	"strconv"
+	"strings"
 )
 
 // extract next valid token from buffer ignoring comment, whitespaces and newlines
 //  1. atEOF tells us if that no more data is available in stream
 //  2. increment inside loop, advance must have incremented value in switch returns
 //  3. eat up anything until end of comment or end of input
+//  4. token or comment may continue in next data chunk, ask scanner for more data
+//  5. only separators were consumed, no token to return, scanner calls again
+//     until no data is left (final token error without token is reported as
+//     an empty token before go 1.22)
 func nextToken(data []byte, atEOF bool) (int, []byte, error) {
 	var (
 		err       error
@@ -43,6 +49,10 @@ func nextToken(data []byte, atEOF bool) (int, []byte, error) {
 				char = data[advance]
 				advance++
 			}
+			// 4.
+			if char != '\n' && !atEOF {
+				return 0, nil, nil
+			}
 		case char >= '0' || char <= '9':
 			token = append(token, char)
 		default:
@@ -50,79 +60,448 @@ func nextToken(data []byte, atEOF bool) (int, []byte, error) {
 		}
 	}
 
+	// 5.
+	if len(token) == 0 {
+		return advance, nil, nil
+	}
+	// 4.
+	if !atEOF {
+		return 0, nil, nil
+	}
 	return advance, token, err
 }
 
+// extract all available bytes from buffer, used to read binary data sections
+func nextChunk(data []byte, atEOF bool) (int, []byte, error) {
+	if len(data) == 0 {
+		return 0, nil, nil
+	}
+	return len(data), data, nil
+}
+
+// nextMagic returns a split function extracting two bytes magic number
+//
+//  1. images following the first one in a stream may be separated by whitespaces
+//  2. nothing left in stream, scanner stops without token
+func nextMagic(skip bool) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		advance := 0
+		// 1.
+		for skip && advance < len(data) && strings.IndexByte(" \t\r\n", data[advance]) != -1 {
+			advance++
+		}
+		if len(data)-advance >= 2 {
+			return advance + 2, data[advance : advance+2], nil
+		}
+		if !atEOF {
+			return advance, nil, nil
+		}
+		// 2.
+		if advance == len(data) {
+			return advance, nil, nil
+		}
+		return len(data), data[advance:], nil
+	}
+}
+
+// splitter holds the current split function of a bufio.Scanner
+//
+// A scanner can't change its split function once scanning has started, binary
+// formats need to switch from nextToken to nextChunk once header has been read
+// without loosing data already buffered by the scanner.
+type splitter struct {
+	split bufio.SplitFunc
+}
+
+func (s *splitter) Split(data []byte, atEOF bool) (int, []byte, error) {
+	return s.split(data, atEOF)
+}
+
+// parseSingle decodes a single image from stream, no data may follow it
+func parseSingle(stream io.Reader, decode func(*Decoder, string) error, accepted ...string) error {
+	decoder := NewDecoder(stream)
+	magic, err := decoder.magic(accepted...)
+	if err != nil {
+		return err
+	}
+	if err := decode(decoder, magic); err != nil {
+		return err
+	}
+	return decoder.end()
+}
+
+// magic reads next magic number and checks that it matches one of accepted
+// values, returns io.EOF when stream holds no more image
+func (d *Decoder) magic(accepted ...string) (string, error) {
+	d.tokenizer.split = nextMagic(d.count != 0)
+	if !d.scanner.Scan() {
+		if err := d.scanner.Err(); err != nil {
+			return "", fmt.Errorf("invalid input: %s", err)
+		}
+		if d.count != 0 {
+			return "", io.EOF
+		}
+		return "", fmt.Errorf("invalid format, expected magic number")
+	}
+
+	d.count++
+	magic := d.scanner.Text()
+	for _, cMagic := range accepted {
+		if magic == cMagic {
+			d.tokenizer.split = nextToken
+			return magic, nil
+		}
+	}
+	return "", fmt.Errorf("invalid magic number '%s', expecting %s", magic, strings.Join(accepted, " or "))
+}
+
+// end checks that nothing but whitespaces and comments follows last image
+//
+// Empty tokens are no data, scanners before go 1.22 return one for final
+// token error without token.
+func (d *Decoder) end() error {
+	d.tokenizer.split = nextToken
+	for d.scanner.Scan() {
+		if len(d.scanner.Bytes()) != 0 {
+			return fmt.Errorf("invalid data, unexpected '%s' after end of image", d.scanner.Text())
+		}
+	}
+	if err := d.scanner.Err(); err != nil {
+		return fmt.Errorf("invalid input: %s", err)
+	}
+	return nil
+}
+
+// number reads next token as a non-negative header value
+func (d *Decoder) number(name string) (int, error) {
+	if !d.scanner.Scan() || d.scanner.Err() != nil {
+		return 0, fmt.Errorf("invalid format, expected image %s: %s", name, d.scanner.Err())
+	}
+	value, err := strconv.Atoi(d.scanner.Text())
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid %s '%s', expecting number", name, d.scanner.Text())
+	}
+	return value, nil
+}
+
+// maxval reads maximum sample value header of PGM and PPM formats
+func (d *Decoder) maxval() (int, error) {
+	maxval, err := d.number("maxval")
+	if err != nil {
+		return 0, err
+	}
+	if maxval == 0 || maxval > PGMMaxval {
+		return 0, fmt.Errorf("invalid maxval '%d', expecting value between 1 and %d", maxval, PGMMaxval)
+	}
+	return maxval, nil
+}
+
+// tokens calls consume for each token of ascii data section until it
+// reports that all expected values were read
+func (d *Decoder) tokens(consume func(token []byte) (bool, error)) error {
+	d.tokenizer.split = nextToken
+	for d.scanner.Scan() {
+		done, err := consume(d.scanner.Bytes())
+		if err != nil || done {
+			return err
+		}
+	}
+	if err := d.scanner.Err(); err != nil {
+		return fmt.Errorf("invalid input: %s", err)
+	}
+	return nil
+}
+
+// chunks calls consume for successive chunks of binary data section
+//
+//  1. never read further than data section, next image may follow
+func (d *Decoder) chunks(size int, consume func(chunk []byte) error) error {
+	remaining := size
+	// 1.
+	d.tokenizer.split = func(data []byte, atEOF bool) (int, []byte, error) {
+		if len(data) > remaining {
+			data = data[:remaining]
+		}
+		return nextChunk(data, atEOF)
+	}
+	for remaining > 0 && d.scanner.Scan() {
+		chunk := d.scanner.Bytes()
+		if err := consume(chunk); err != nil {
+			return err
+		}
+		remaining -= len(chunk)
+	}
+	if err := d.scanner.Err(); err != nil {
+		return fmt.Errorf("invalid input: %s", err)
+	}
+
+	if remaining != 0 {
+		return fmt.Errorf("invalid data, got '%d' out of '%d' expected bytes", size-remaining, size)
+	}
+	return nil
+}
+
 func (i *Image) parse(stream io.Reader) error {
-	if err := i.parseMagic(stream); err != nil {
+	return parseSingle(stream, i.decode, PBMMagicP1, PBMMagicP4)
+}
+
+func (i *Image) decode(d *Decoder, magic string) error {
+	if err := i.decodeHeader(d); err != nil {
 		return err
 	}
 
-	scanner := bufio.NewScanner(stream)
-	scanner.Split(nextToken)
+	*i = *newImage(i.width, i.height)
+	if magic == PBMMagicP4 {
+		return i.decodeBinaryData(d)
+	}
+	return i.decodeData(d)
+}
 
-	if err := i.parseHeader(scanner); err != nil {
+func (i *Image) decodeHeader(d *Decoder) error {
+	var err error
+
+	if i.width, err = d.number("width"); err != nil {
+		return err
+	}
+	if i.height, err = d.number("height"); err != nil {
 		return err
 	}
-	return i.parseData(scanner)
+	return nil
+}
+
+// pixelReader - state of streaming parser of ascii bitmap data section
+//
+// Parser runs as a split function of decoder scanner: it consumes whole
+// buffer at each call and writes pixels to image as it reads them, so that
+// scanner buffer never grows and no token is allocated. It only returns an
+// empty token once all pixels were read.
+type pixelReader struct {
+	image   *Image
+	size    int   // number of expected pixels
+	index   int   // number of pixels read so far
+	x, y    int   // coordinates of next pixel, tracked along index to avoid divisions
+	comment bool  // inside a comment, until end of line
+	err     error // invalid data, reported as is
+}
+
+// split consumes given data, see pixelReader
+//
+// Pixels are '0' and '1' digits, separated or not by spaces and newlines,
+// comments run from '#' to end of line.
+//
+//  1. last pixel must end its run of digits, data may follow image
+//  2. remaining data belongs to next image or is checked by Decoder.end
+//  3. lowest bit of '1' digit is set, unlike '0', no branch on pixel value
+func (r *pixelReader) split(data []byte, atEOF bool) (int, []byte, error) {
+	for advance, char := range data {
+		switch {
+		case r.comment:
+			r.comment = char != '\n'
+		case (char == '0' || char == '1') && r.index < r.size:
+			// 3.
+			r.image.data[r.x/wordBits+r.y*r.image.stride] |= uint64(char&1) << (wordBits - 1 - r.x%wordBits)
+			r.index, r.x = r.index+1, r.x+1
+			if r.x == r.image.width {
+				r.x, r.y = 0, r.y+1
+			}
+		case char == '#':
+			r.comment = true
+		case char == ' ', char == '\n':
+			if r.index == r.size {
+				return advance, data[advance:advance], nil
+			}
+		case r.index == r.size:
+			// 1.
+			if char == '0' || char == '1' {
+				r.err = fmt.Errorf("invalid data, expecting no more than %d pixels", r.size)
+			} else {
+				r.err = fmt.Errorf("invalid pixel value '%c', expecting 0 or 1", char)
+			}
+			return advance, nil, r.err
+		default:
+			r.err = fmt.Errorf("invalid pixel value '%c', expecting 0 or 1", char)
+			return advance, nil, r.err
+		}
+	}
+	if !atEOF {
+		return len(data), nil, nil
+	}
+	if r.index != r.size {
+		r.err = fmt.Errorf("invalid data, got '%d' out of '%d' expected pixels", r.index, r.size)
+		return len(data), nil, r.err
+	}
+	// 2.
+	return len(data), []byte{}, nil
 }
 
-func (i *Image) parseMagic(stream io.Reader) error {
-	buffer := make([]byte, 2)
-	count, err := stream.Read(buffer)
-	if err != nil || count != 2 {
-		return fmt.Errorf("invalid format, expected magic number")
+// parse ascii data section with a streaming pixelReader
+func (i *Image) decodeData(d *Decoder) error {
+	reader := &pixelReader{image: i, size: i.width * i.height}
+	d.tokenizer.split = reader.split
+	if d.scanner.Scan() {
+		return nil
 	}
-	if string(buffer) != PBMMagicP1 {
-		return fmt.Errorf("invalid magic number '%s', expecting %s", string(buffer), PBMMagicP1)
+	if reader.err != nil {
+		return reader.err
+	}
+	if err := d.scanner.Err(); err != nil {
+		return fmt.Errorf("invalid input: %s", err)
+	}
+	return fmt.Errorf("invalid data, got '%d' out of '%d' expected pixels", reader.index, reader.size)
+}
+
+// parse binary data section
+//
+// Each row is packed 8 pixels per byte, most significant bit first, and padded
+// to a full byte. This matches image words layout, bytes are copied as is,
+// eight at a time when available. Padding bits are ignored.
+//
+//  1. index of byte in data section, gives row and column of its first pixel
+//  2. padding bits of input must not become pixels of wider images
+func (i *Image) decodeBinaryData(d *Decoder) error {
+	var (
+		rowBytes = (i.width + 7) / 8
+		// 1.
+		index = 0
+	)
+
+	err := d.chunks(rowBytes*i.height, func(chunk []byte) error {
+		for len(chunk) != 0 {
+			y, column := index/rowBytes, index%rowBytes
+			word := &i.data[column/8+y*i.stride]
+			if column%8 == 0 && column+8 <= rowBytes && len(chunk) >= 8 {
+				*word = binary.BigEndian.Uint64(chunk)
+				chunk, index = chunk[8:], index+8
+				continue
+			}
+			*word |= uint64(chunk[0]) << (56 - 8*(column%8))
+			chunk, index = chunk[1:], index+1
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// 2.
+	for y := 0; y < i.height && i.stride != 0; y++ {
+		i.data[(y+1)*i.stride-1] &= i.tail()
 	}
 	return nil
 }
 
-func (i *Image) parseHeader(scanner *bufio.Scanner) error {
+func (g *GrayImage) parse(stream io.Reader) error {
+	return parseSingle(stream, g.decode, PGMMagicP2, PGMMagicP5)
+}
+
+func (g *GrayImage) decode(d *Decoder, magic string) error {
 	var err error
 
-	if !scanner.Scan() || scanner.Err() != nil {
-		return fmt.Errorf("invalid format, expected image width: %s", scanner.Err())
+	if g.width, err = d.number("width"); err != nil {
+		return err
 	}
-	i.width, err = strconv.Atoi(scanner.Text())
-	if err != nil {
-		return fmt.Errorf("invalid width '%s', expecting number", scanner.Text())
+	if g.height, err = d.number("height"); err != nil {
+		return err
+	}
+	if g.maxval, err = d.maxval(); err != nil {
+		return err
+	}
+
+	g.data = make([]uint16, g.width*g.height)
+	if magic == PGMMagicP5 {
+		return d.binarySamples(g.data, g.maxval)
+	}
+	return d.samples(g.data, g.maxval)
+}
+
+func (c *ColorImage) parse(stream io.Reader) error {
+	return parseSingle(stream, c.decode, PPMMagicP3, PPMMagicP6)
+}
+
+func (c *ColorImage) decode(d *Decoder, magic string) error {
+	var err error
+
+	if c.width, err = d.number("width"); err != nil {
+		return err
+	}
+	if c.height, err = d.number("height"); err != nil {
+		return err
+	}
+	if c.maxval, err = d.maxval(); err != nil {
+		return err
 	}
 
-	if !scanner.Scan() || scanner.Err() != nil {
-		return fmt.Errorf("invalid format, expected image height: %s", scanner.Err())
+	samples := make([]uint16, c.width*c.height*3)
+	if magic == PPMMagicP6 {
+		err = d.binarySamples(samples, c.maxval)
+	} else {
+		err = d.samples(samples, c.maxval)
 	}
-	i.height, err = strconv.Atoi(scanner.Text())
 	if err != nil {
-		return fmt.Errorf("invalid height '%s', expecting number", scanner.Text())
+		return err
 	}
 
+	c.data = make([]rgb, c.width*c.height)
+	for cIdx := range c.data {
+		c.data[cIdx] = rgb{samples[cIdx*3], samples[cIdx*3+1], samples[cIdx*3+2]}
+	}
 	return nil
 }
 
-func (i *Image) parseData(scanner *bufio.Scanner) error {
-	i.data = make([]bool, i.width*i.height)
+// parse ascii data section, one decimal token per sample
+func (d *Decoder) samples(samples []uint16, maxval int) error {
 	index := 0
-	for scanner.Scan() {
-		if err := scanner.Err(); err != nil {
-			return fmt.Errorf("invalid input: %s", err)
-		}
-		for _, digit := range scanner.Text() {
-			if digit != '0' && digit != '1' {
-				return fmt.Errorf("invalid pixel value '%d', expecting 0 or 1", digit)
-			}
-			if index >= (i.width * i.height) {
-				return fmt.Errorf("invalid data, expecting no more than %d pixels", i.width*i.height)
-			}
-			i.data[index] = (digit == '1')
-			index++
+	err := d.tokens(func(token []byte) (bool, error) {
+		value, err := strconv.Atoi(string(token))
+		if err != nil || value < 0 || value > maxval {
+			return false, fmt.Errorf("invalid sample value '%s', expecting 0 to %d", token, maxval)
 		}
+		samples[index] = uint16(value)
+		index++
+		return index == len(samples), nil
+	})
+	if err != nil {
+		return err
 	}
 
-	if index != (i.width * i.height) {
-		return fmt.Errorf("invalid data, got '%d' out of '%d' expected pixels", index, (i.width * i.height))
+	if index != len(samples) {
+		return fmt.Errorf("invalid data, got '%d' out of '%d' expected samples", index, len(samples))
 	}
 
 	return nil
 }
+
+// parse binary data section
+//
+// Samples are stored on one byte when maxval fits in it, otherwise on two
+// bytes, most significant byte first.
+//
+//  1. a chunk may end in the middle of a two bytes sample, data index is
+//     computed from global byte index rather than chunk position
+func (d *Decoder) binarySamples(samples []uint16, maxval int) error {
+	var (
+		size  = sampleSize(maxval)
+		index = 0
+	)
+
+	return d.chunks(len(samples)*size, func(chunk []byte) error {
+		for _, value := range chunk {
+			// 1.
+			sample := index / size
+			samples[sample] = samples[sample]<<8 | uint16(value)
+			if (index+1)%size == 0 && int(samples[sample]) > maxval {
+				return fmt.Errorf("invalid sample value '%d', expecting 0 to %d", samples[sample], maxval)
+			}
+			index++
+		}
+		return nil
+	})
+}
+
+// sampleSize gives number of bytes used by raw formats to store a sample
+func sampleSize(maxval int) int {
+	if maxval > 255 {
+		return 2
+	}
+	return 1
+}
@@ -5,7 +5,9 @@
 package pbm
 
 import (
+	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 func TestParse_empty(t *testing.T) {
@@ -33,7 +35,7 @@ func TestParse_invalidMagic(t *testing.T) {
 }
 
 func TestParse_incorrectMagic(t *testing.T) {
-	input := "P4 2 2 0000"
+	input := "P3 2 2 0000"
 	_, err := NewImageFromString(input)
 	if err == nil {
 		t.Fatalf("should have fail: incorrect magic number")
@@ -65,16 +67,16 @@ func expect(t *testing.T, image *Image, err error, width, height int, data strin
 	if width != image.width {
 		t.Fatalf("expected width %d, got '%d'", width, image.width)
 	}
-	if width != image.height {
+	if height != image.height {
 		t.Fatalf("expected height %d, got '%d'", height, image.height)
 	}
-	for cIdx, cPixel := range image.data {
+	for cIdx, cPixel := range image.pixels() {
 		want := "0"
 		if cPixel {
 			want = "1"
 		}
 		if string(data[cIdx]) != want {
-			t.Fatalf("unexpected data result, what '%s', got '%v'", data, image.data)
+			t.Fatalf("unexpected data result, what '%s', got '%v'", data, image.pixels())
 		}
 	}
 }
@@ -177,3 +179,224 @@ func TestParse_missingData(t *testing.T) {
 		t.Fatalf("should have fail: not enough data")
 	}
 }
+
+func TestParse_splitData(t *testing.T) {
+	// digits runs and comments spanning over several reads
+	input := "P1\n3 2\n10# grandma's\n0 0\n11 \n"
+	image := &Image{}
+	err := image.parse(iotest.OneByteReader(strings.NewReader(input)))
+	expect(t, image, err, 3, 2, "100011")
+
+	// last pixel followed by more digits in next read
+	image = &Image{}
+	err = image.parse(iotest.OneByteReader(strings.NewReader("P1 1 1 10")))
+	if err == nil {
+		t.Fatalf("should have fail: too much data")
+	}
+}
+
+func TestParse_separatorsToken(t *testing.T) {
+	// trailing separators and comments give no token, nor final token error
+	// which scanners before go 1.22 report as an empty token
+	for _, cInput := range []string{" \n", "# grandma\n", "\n# grandma"} {
+		advance, token, err := nextToken([]byte(cInput), true)
+		if advance != len(cInput) || token != nil || err != nil {
+			t.Fatalf("unexpected result %d, %q, %v for %q", advance, token, err, cInput)
+		}
+	}
+}
+
+func TestParse_binary(t *testing.T) {
+	// raw format, one byte per row
+	input := "P4\n2 2\n\x80\x40"
+	image, err := NewImageFromString(input)
+	expect(t, image, err, 2, 2, "1001")
+}
+
+func TestParse_binaryPadding(t *testing.T) {
+	// raw format, rows padded to full byte and padding bits ignored
+	input := "P4 # grandma's raw memory\n10 10\n" +
+		"\xff\xc0\x00\x00\x80\x40\x40\x3f\x20\x00\x10\x00\x08\x00\x04\x00\x02\x00\x01\x00"
+	image, err := NewImageFromString(input)
+	expect(t, image, err, 10, 10, ""+
+		"1111111111"+
+		"0000000000"+
+		"1000000001"+
+		"0100000000"+
+		"0010000000"+
+		"0001000000"+
+		"0000100000"+
+		"0000010000"+
+		"0000001000"+
+		"0000000100")
+}
+
+func TestParse_binaryTooMuchData(t *testing.T) {
+	input := "P4 2 2 \x00\x00\x00"
+	_, err := NewImageFromString(input)
+	if err == nil {
+		t.Fatalf("should have fail: too much data")
+	}
+}
+
+func TestParse_binaryMissingData(t *testing.T) {
+	input := "P4 2 2 \x00"
+	_, err := NewImageFromString(input)
+	if err == nil {
+		t.Fatalf("should have fail: not enough data")
+	}
+}
+
+func TestParse_binaryMatchesASCII(t *testing.T) {
+	ascii, err := NewImageFromString("P1 9 2 100000001 011111110")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	binary, err := NewImageFromString("P4 9 2 \x80\x80\x7f\x00")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for cIdx := range ascii.data {
+		if ascii.data[cIdx] != binary.data[cIdx] {
+			t.Fatalf("unexpected data at index %d, want '%v', got '%v'", cIdx, ascii.data, binary.data)
+		}
+	}
+}
+
+func expectGray(t *testing.T, image *GrayImage, err error, width, height, maxval int, data []uint16) {
+	t.Helper()
+
+	if err != nil {
+		t.Fatalf("expected parse error: %s", err)
+	}
+	if width != image.width || height != image.height || maxval != image.maxval {
+		t.Fatalf("expected %dx%d (maxval %d), got %dx%d (maxval %d)",
+			width, height, maxval, image.width, image.height, image.maxval)
+	}
+	if len(data) != len(image.data) {
+		t.Fatalf("unexpected data result, want '%v', got '%v'", data, image.data)
+	}
+	for cIdx, cSample := range image.data {
+		if data[cIdx] != cSample {
+			t.Fatalf("unexpected data result, want '%v', got '%v'", data, image.data)
+		}
+	}
+}
+
+func TestParseGray_simple(t *testing.T) {
+	input := `P2
+# grandma's first gray memory
+3 2
+255
+0 10 255
+128 64 32
+`
+	image, err := NewGrayImageFromString(input)
+	expectGray(t, image, err, 3, 2, 255, []uint16{0, 10, 255, 128, 64, 32})
+}
+
+func TestParseGray_binary(t *testing.T) {
+	input := "P5\n3 1\n255\n\x00\x0a\xff"
+	image, err := NewGrayImageFromString(input)
+	expectGray(t, image, err, 3, 1, 255, []uint16{0, 10, 255})
+}
+
+func TestParseGray_binaryWide(t *testing.T) {
+	input := "P5\n2 1\n65535\n\x01\x02\xff\xff"
+	image, err := NewGrayImageFromString(input)
+	expectGray(t, image, err, 2, 1, 65535, []uint16{258, 65535})
+}
+
+func TestParseGray_invalidMaxval(t *testing.T) {
+	for _, input := range []string{"P2 1 1 0 0", "P2 1 1 65536 0", "P2 1 1 x 0"} {
+		if _, err := NewGrayImageFromString(input); err == nil {
+			t.Fatalf("should have fail: invalid maxval in '%s'", input)
+		}
+	}
+}
+
+func TestParseGray_invalidData(t *testing.T) {
+	inputs := []string{
+		"P1 1 1 1",
+		"P2 1 1 15 16",
+		"P2 2 1 15 1",
+		"P2 1 1 15 1 1",
+		"P5 1 1 15 \x10",
+		"P5 1 1 255 \x10\x10",
+		"P5 1 1 65535 \x10",
+	}
+	for _, input := range inputs {
+		if _, err := NewGrayImageFromString(input); err == nil {
+			t.Fatalf("should have fail: invalid data in '%s'", input)
+		}
+	}
+}
+
+func TestParseGray_splitTokens(t *testing.T) {
+	// tokens and comments spanning over several reads
+	input := "P2\n# grandma's\n2 1\n1023\n1000 # dearest memory\n999\n"
+	image := &GrayImage{}
+	err := image.parse(iotest.OneByteReader(strings.NewReader(input)))
+	expectGray(t, image, err, 2, 1, 1023, []uint16{1000, 999})
+}
+
+func expectColor(t *testing.T, image *ColorImage, err error, width, height, maxval int, data []rgb) {
+	t.Helper()
+
+	if err != nil {
+		t.Fatalf("expected parse error: %s", err)
+	}
+	if width != image.width || height != image.height || maxval != image.maxval {
+		t.Fatalf("expected %dx%d (maxval %d), got %dx%d (maxval %d)",
+			width, height, maxval, image.width, image.height, image.maxval)
+	}
+	if len(data) != len(image.data) {
+		t.Fatalf("unexpected data result, want '%v', got '%v'", data, image.data)
+	}
+	for cIdx, cPixel := range image.data {
+		if data[cIdx] != cPixel {
+			t.Fatalf("unexpected data result, want '%v', got '%v'", data, image.data)
+		}
+	}
+}
+
+func TestParseColor_simple(t *testing.T) {
+	input := `P3
+# grandma's first color memory
+2 2
+255
+255 0 0   0 255 0
+0 0 255   255 255 255
+`
+	image, err := NewColorImageFromString(input)
+	expectColor(t, image, err, 2, 2, 255, []rgb{{255, 0, 0}, {0, 255, 0}, {0, 0, 255}, {255, 255, 255}})
+}
+
+func TestParseColor_binary(t *testing.T) {
+	input := "P6\n2 1\n255\n\xff\x00\x00\x00\x10\x20"
+	image, err := NewColorImageFromString(input)
+	expectColor(t, image, err, 2, 1, 255, []rgb{{255, 0, 0}, {0, 16, 32}})
+}
+
+func TestParseColor_binaryWide(t *testing.T) {
+	input := "P6\n1 1\n65535\n\xff\xff\x01\x00\x00\x02"
+	image, err := NewColorImageFromString(input)
+	expectColor(t, image, err, 1, 1, 65535, []rgb{{65535, 256, 2}})
+}
+
+func TestParseColor_invalidData(t *testing.T) {
+	inputs := []string{
+		"P2 1 1 255 0",
+		"P3 1 1 0 0 0 0",
+		"P3 1 1 255 0 0",
+		"P3 1 1 255 0 0 0 0",
+		"P3 1 1 255 0 256 0",
+		"P6 1 1 255 \x00\x00",
+		"P6 1 1 65535 \x00\x00\x00\x00\x00",
+	}
+	for _, input := range inputs {
+		if _, err := NewColorImageFromString(input); err == nil {
+			t.Fatalf("should have fail: invalid data in '%s'", input)
+		}
+	}
+}
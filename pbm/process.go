@@ -5,86 +5,324 @@
 package pbm
 
 import (
+	"image"
 	"math"
 )
 
 // Rotator computes rotated coordinates for a given (x,y) point
 // Stores constant values for a given rotation of a given image
+//
+// Rotation is the affine transformation returned by AffineRotate.
 type Rotator struct {
-	x0      float64 // x coordinates of center of rotation
-	y0      float64 // y coordinates of center of rotation
-	sinθ    float64 // value of sin(angle)
-	cosθ    float64 // value of cos(angle)
-	offsetX float64 // shift of x coordinates for when center falls in inter-pixel
-	offsetY float64 // shift of y coordinates for when center falls in inter-pixel
+	Transformer
 }
 
 // NewRotator initializes rotator object
 //
-//  1. constant values for given angle and image
-//  2. when center of rotation is on an inter-pixel for only one coordinates
-//     translate point to half a pixel
-func NewRotator(angle float64, image *Image) *Rotator {
-	// 1.
-	θ := angle * (math.Pi / float64(180))
-	x0 := float64(image.Width()-1) / 2.0
-	y0 := float64(image.Height()-1) / 2.0
-
-	// 2.
-	offsetX := 0.0
-	offsetY := 0.0
-	if image.Width()%2 == 1 && image.Height()%2 == 0 {
-		offsetX = 0.5
-	}
-	if image.Width()%2 == 0 && image.Height()%2 == 1 {
-		offsetY = 0.5
-	}
-
-	return &Rotator{
-		x0:      x0,
-		y0:      y0,
-		sinθ:    math.Sin(θ),
-		cosθ:    math.Cos(θ),
-		offsetX: offsetX,
-		offsetY: offsetY,
-	}
+// Center of rotation is the geometric center of image unless WithCenter,
+// WithAnchor or WithCentroid option is given.
+func NewRotator(angle float64, img Sizer, opts ...Option) *Rotator {
+	// rotations are always invertible
+	transformer, _ := NewTransformer(AffineRotate(angle), img, opts...)
+	return &Rotator{*transformer}
 }
 
-// RotateCoord computes pixel new coordinates after rotation
-func (r *Rotator) Compute(x int, y int) (int, int) {
-	x1 := r.cosθ*(float64(x)-r.x0) - r.sinθ*(float64(y)-r.y0) + r.x0 + r.offsetX
-	y1 := r.sinθ*(float64(x)-r.x0) + r.cosθ*(float64(y)-r.y0) + r.y0 + r.offsetY
+// mapper computes transformed coordinates of pixels, see Transformer and
+// Shearer
+type mapper interface {
+	Compute(x int, y int) (int, int)
+	Inverse(x int, y int) (int, int)
+}
 
-	return int(math.Round(x1)), int(math.Round(y1))
+// rotatePixels returns a rotated copy of given pixels
+//
+// Result covers given bounds in rotated coordinates space. Source pixels are
+// projected to their destination when forward is true, otherwise destination
+// pixels are sampled from source.
+func rotatePixels[T comparable](data []T, width, height int, rotator mapper, bounds image.Rectangle, blank T, forward bool, workers int) []T {
+	if forward {
+		return forwardPixels(data, width, height, rotator, bounds, blank)
+	}
+	return inversePixels(data, width, height, rotator, bounds, blank, workers)
 }
 
-// Rotate image to given angle
+// forwardPixels projects each source pixel to its destination
 //
-// Pixels projected outside image boundaries will be lost.
+// Pixels projected outside bounds will be lost.
 //
-//  1. working buffer is already full of white pixels, we only need to rotate black ones
+//  1. working buffer is filled with blank pixels, we only need to rotate others
 //  2. discard out-of-bound pixel coordinates
-func (i *Image) Rotate(angle float64) {
-	rotator := NewRotator(angle, i)
-
-	result := make([]bool, i.width*i.height)
-	for x := 0; x < i.width; x++ {
-		for y := 0; y < i.height; y++ {
-			// 1.
-			if !i.data[x+y*i.width] {
+func forwardPixels[T comparable](data []T, width, height int, rotator mapper, bounds image.Rectangle, blank T) []T {
+	var zero T
+
+	// 1.
+	result := make([]T, bounds.Dx()*bounds.Dy())
+	if blank != zero {
+		for cIdx := range result {
+			result[cIdx] = blank
+		}
+	}
+
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			pixel := data[x+y*width]
+			if pixel == blank {
 				continue
 			}
 
 			pixelX, pixelY := rotator.Compute(x, y)
 
 			// 2.
-			if pixelX < 0 || pixelX >= i.width || pixelY < 0 || pixelY >= i.height {
+			if !(image.Point{pixelX, pixelY}.In(bounds)) {
 				continue
 			}
 
-			index := pixelX + pixelY*i.width
-			result[index] = true
+			result[pixelX-bounds.Min.X+(pixelY-bounds.Min.Y)*bounds.Dx()] = pixel
+		}
+	}
+	return result
+}
+
+// inversePixels samples each destination pixel from its source
+//
+// Every destination pixel is defined exactly once, those which source falls
+// outside of image are blank. Bands of destination rows are processed by
+// given number of workers.
+func inversePixels[T comparable](data []T, width, height int, rotator mapper, bounds image.Rectangle, blank T, workers int) []T {
+	result := make([]T, bounds.Dx()*bounds.Dy())
+	parallelRows(bounds.Dy(), workers, func(top, bottom int) {
+		for y := bounds.Min.Y + top; y < bounds.Min.Y+bottom; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				pixel := blank
+				sourceX, sourceY := rotator.Inverse(x, y)
+				if sourceX >= 0 && sourceX < width && sourceY >= 0 && sourceY < height {
+					pixel = data[sourceX+sourceY*width]
+				}
+				result[x-bounds.Min.X+(y-bounds.Min.Y)*bounds.Dx()] = pixel
+			}
+		}
+	})
+	return result
+}
+
+// quarterTurns gives number of clockwise quarter turns matching given angle,
+// second value is false when angle is not a multiple of 90 degrees
+func quarterTurns(angle float64) (int, bool) {
+	if math.Mod(angle, 90) != 0 {
+		return 0, false
+	}
+	turns := int(math.Mod(angle/90, 4))
+	if turns < 0 {
+		turns += 4
+	}
+	return turns, true
+}
+
+// turnPixels returns a copy of given pixels rotated by given number of
+// clockwise quarter turns
+//
+// Rotation is an exact permutation of pixels, width and height are swapped
+// for odd number of turns.
+func turnPixels[T any](data []T, width, height int, turns int, workers int) []T {
+	return orientPixels(data, width, height, turnOrientations[turns], workers)
+}
+
+// size - dimensions of an image without its pixels
+type size struct {
+	width  int
+	height int
+}
+
+func (s size) Width() int  { return s.width }
+func (s size) Height() int { return s.height }
+
+// transformation - prepared transformation of an image
+type transformation struct {
+	mapper  mapper          // coordinates mapping, nil when rotation is exact
+	bounds  image.Rectangle // bounds of result image
+	forward bool            // use forward mapping
+	turns   int             // number of clockwise quarter turns applied before mapping
+	workers int             // number of concurrent workers, GOMAXPROCS when zero
+}
+
+// newRotation prepares rotation of given image according to options
+//
+//  1. right angles are exact pixel permutations, canvas always holds whole
+//     rotated image, unless an explicit center of rotation must keep its
+//     position in source frame
+//  2. shears are reduced to angles between -45 and 45 degrees after quarter
+//     turns, result frame keeps center of rotation at the same position
+func newRotation(angle float64, img Sizer, opts []Option) *transformation {
+	settings := newOptions(opts)
+	result := &transformation{
+		bounds:  image.Rect(0, 0, img.Width(), img.Height()),
+		forward: settings.forward,
+		workers: settings.workers,
+	}
+
+	// 1.
+	if turns, exact := quarterTurns(angle); exact && settings.center == nil {
+		result.turns = turns
+		if turns%2 == 1 {
+			result.bounds = image.Rect(0, 0, img.Height(), img.Width())
+		}
+		return result
+	}
+
+	if settings.algorithm == AlgorithmShear {
+		// 2.
+		quarters := math.Round(angle / 90)
+		result.turns, _ = quarterTurns(quarters * 90)
+		var turned Sizer = img
+		if result.turns%2 == 1 {
+			turned = size{img.Height(), img.Width()}
 		}
+		x0, y0 := settings.pivot(img)
+		shearX, shearY := turnPoint(x0, y0, img, result.turns)
+		shearer := NewShearer(angle-quarters*90, turned, WithCenter(shearX, shearY))
+		result.mapper = shearer
+		result.bounds = shearer.Frame(img.Width(), img.Height(), x0, y0)
+		if settings.resize {
+			result.bounds = shearer.Bounds()
+		}
+		return result
+	}
+
+	rotator := NewRotator(angle, img, opts...)
+	result.mapper = rotator
+	if settings.resize {
+		result.bounds = rotator.Bounds()
+	}
+	return result
+}
+
+// newTransformation prepares affine transformation of given image according
+// to options, fails when transformation can't be inverted
+func newTransformation(affine Affine, img Sizer, opts []Option) (*transformation, error) {
+	settings := newOptions(opts)
+	transformer, err := NewTransformer(affine, img, opts...)
+	if err != nil {
+		return nil, err
+	}
+	result := &transformation{
+		mapper:  transformer,
+		bounds:  image.Rect(0, 0, img.Width(), img.Height()),
+		forward: settings.forward,
+		workers: settings.workers,
+	}
+	if settings.resize {
+		result.bounds = transformer.Bounds()
+	}
+	return result, nil
+}
+
+// apply returns transformed copy of given pixels
+func apply[T comparable](r *transformation, data []T, width, height int, blank T) []T {
+	if r.turns != 0 {
+		data = turnPixels(data, width, height, r.turns, r.workers)
+		if r.turns%2 == 1 {
+			width, height = height, width
+		}
+	}
+	if r.mapper == nil {
+		return data
+	}
+	return rotatePixels(data, width, height, r.mapper, r.bounds, blank, r.forward, r.workers)
+}
+
+// Rotate image to given angle
+//
+// Pixels projected outside image boundaries will be lost unless WithResize
+// option is given, uncovered pixels are white.
+//
+// Multiples of 90 degrees are lossless, width and height are swapped for
+// quarter turns.
+func (i *Image) Rotate(angle float64, opts ...Option) {
+	*i = *transformBits(i, newRotation(angle, i, opts))
+}
+
+// Rotate image to given angle
+//
+// Pixels projected outside image boundaries will be lost unless WithResize
+// option is given, uncovered pixels are white, or transparent when image has
+// an alpha channel.
+//
+// Multiples of 90 degrees are lossless, width and height are swapped for
+// quarter turns.
+func (g *GrayImage) Rotate(angle float64, opts ...Option) {
+	rotation := newRotation(angle, g, opts)
+	g.data = apply(rotation, g.data, g.width, g.height, uint16(g.maxval))
+	if g.HasAlpha() {
+		g.alpha = apply(rotation, g.alpha, g.width, g.height, 0)
+	}
+	g.width, g.height = rotation.bounds.Dx(), rotation.bounds.Dy()
+}
+
+// Rotate image to given angle
+//
+// Pixels projected outside image boundaries will be lost unless WithResize
+// option is given, uncovered pixels are white, or transparent when image has
+// an alpha channel.
+//
+// Multiples of 90 degrees are lossless, width and height are swapped for
+// quarter turns.
+func (c *ColorImage) Rotate(angle float64, opts ...Option) {
+	rotation := newRotation(angle, c, opts)
+	c.data = apply(rotation, c.data, c.width, c.height, c.white())
+	if c.HasAlpha() {
+		c.alpha = apply(rotation, c.alpha, c.width, c.height, 0)
+	}
+	c.width, c.height = rotation.bounds.Dx(), rotation.bounds.Dy()
+}
+
+// Transform applies given affine transformation to image
+//
+// Transformation is relative to image center, see NewTransformer. Boundaries
+// are handled the same way as Rotate: pixels projected outside image will be
+// lost unless WithResize option is given, uncovered pixels are white.
+func (i *Image) Transform(affine Affine, opts ...Option) error {
+	transformation, err := newTransformation(affine, i, opts)
+	if err != nil {
+		return err
+	}
+	*i = *transformBits(i, transformation)
+	return nil
+}
+
+// Transform applies given affine transformation to image
+//
+// Transformation is relative to image center, see NewTransformer. Boundaries
+// are handled the same way as Rotate: pixels projected outside image will be
+// lost unless WithResize option is given, uncovered pixels are white, or
+// transparent when image has an alpha channel.
+func (g *GrayImage) Transform(affine Affine, opts ...Option) error {
+	transformation, err := newTransformation(affine, g, opts)
+	if err != nil {
+		return err
+	}
+	g.data = apply(transformation, g.data, g.width, g.height, uint16(g.maxval))
+	if g.HasAlpha() {
+		g.alpha = apply(transformation, g.alpha, g.width, g.height, 0)
+	}
+	g.width, g.height = transformation.bounds.Dx(), transformation.bounds.Dy()
+	return nil
+}
+
+// Transform applies given affine transformation to image
+//
+// Transformation is relative to image center, see NewTransformer. Boundaries
+// are handled the same way as Rotate: pixels projected outside image will be
+// lost unless WithResize option is given, uncovered pixels are white, or
+// transparent when image has an alpha channel.
+func (c *ColorImage) Transform(affine Affine, opts ...Option) error {
+	transformation, err := newTransformation(affine, c, opts)
+	if err != nil {
+		return err
+	}
+	c.data = apply(transformation, c.data, c.width, c.height, c.white())
+	if c.HasAlpha() {
+		c.alpha = apply(transformation, c.alpha, c.width, c.height, 0)
 	}
-	i.data = result
+	c.width, c.height = transformation.bounds.Dx(), transformation.bounds.Dy()
+	return nil
 }
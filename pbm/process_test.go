@@ -9,19 +9,23 @@ import (
 	"testing"
 )
 
-func checkRotation(t *testing.T, angle float64, in string, expect string) {
+// checkOperation applies given operation to image parsed from in and
+// compares its ascii representation to expect
+func checkOperation(t *testing.T, in string, expect string, operation func(*Image) error) {
 	t.Helper()
 
 	img, err := NewImageFromString(in)
 	if err != nil {
-		t.Fatalf("expected parse error: %s", err)
+		t.Fatalf("unexpected parse error: %s", err)
 	}
 
-	img.Rotate(angle)
+	if err := operation(img); err != nil {
+		t.Fatalf("unexpected operation error: %s", err)
+	}
 
 	writer := bytes.Buffer{}
 	if err := img.EncodeASCII(&writer); err != nil {
-		t.Fatalf("expected encode error: %s", err)
+		t.Fatalf("unexpected encode error: %s", err)
 	}
 
 	if writer.String() != expect {
@@ -29,6 +33,15 @@ func checkRotation(t *testing.T, angle float64, in string, expect string) {
 	}
 }
 
+func checkRotation(t *testing.T, angle float64, in string, expect string, opts ...Option) {
+	t.Helper()
+
+	checkOperation(t, in, expect, func(img *Image) error {
+		img.Rotate(angle, opts...)
+		return nil
+	})
+}
+
 func TestRotate_invariants(t *testing.T) {
 	in := `P1
 3 3
@@ -190,10 +203,13 @@ func TestRotate_evenWidthRect(t *testing.T) {
 000000
 `
 	out := `P1
-6 3
-000100
-000100
-000100
+3 6
+000
+010
+010
+010
+010
+000
 `
 	checkRotation(t, 90, in, out)
 }
@@ -210,13 +226,10 @@ func TestRotate_evenHeight(t *testing.T) {
 010
 `
 	out := `P1
-3 6
-000
-000
-000
-111
-000
-000
+6 3
+000000
+111111
+000000
 `
 	checkRotation(t, 90, in, out)
 }
@@ -233,15 +246,66 @@ func TestRotate_evenHeight2(t *testing.T) {
 010
 `
 	out := `P1
-3 6
-000
-000
-001
-111
-001
-000
+6 3
+000100
+111111
+000100
+`
+	checkRotation(t, 90, in, out)
+}
+
+func TestRotate_rightAngles(t *testing.T) {
+	// non-square image, width and height are swapped
+	in := `P1
+3 2
+100
+011
+`
+	out := `P1
+2 3
+01
+10
+10
 `
 	checkRotation(t, 90, in, out)
+	checkRotation(t, -270, in, out)
+
+	out = `P1
+3 2
+110
+001
+`
+	checkRotation(t, 180, in, out)
+	checkRotation(t, -180, in, out)
+
+	out = `P1
+2 3
+01
+01
+10
+`
+	checkRotation(t, 270, in, out)
+	checkRotation(t, -90, in, out)
+}
+
+func TestRotate_fourQuarters(t *testing.T) {
+	// four quarter turns reproduce input on any size
+	for _, in := range []string{"P1\n0 0\n", "P1\n1 1\n1\n", "P1\n2 1\n10\n", "P1\n5 4\n10010\n01101\n00001\n11000\n"} {
+		img, err := NewImageFromString(in)
+		if err != nil {
+			t.Fatalf("unexpected parse error: %s", err)
+		}
+		for cIdx := 0; cIdx < 4; cIdx++ {
+			img.Rotate(90)
+		}
+		writer := bytes.Buffer{}
+		if err := img.EncodeASCII(&writer); err != nil {
+			t.Fatalf("unexpected encode error: %s", err)
+		}
+		if writer.String() != in {
+			t.Fatalf("unexpected output: %s", writer.String())
+		}
+	}
 }
 
 func TestRotate_clock(t *testing.T) {
@@ -274,7 +338,7 @@ func TestRotate_clock(t *testing.T) {
 00000000000
 00000000000
 `
-	checkRotation(t, 45, in, out)
+	checkRotation(t, 45, in, out, WithForwardMapping())
 
 	out = `P1
 11 11
@@ -290,5 +354,213 @@ func TestRotate_clock(t *testing.T) {
 00000000000
 00000000000
 `
-	checkRotation(t, 67.5, in, out)
+	checkRotation(t, 67.5, in, out, WithForwardMapping())
+}
+
+func TestRotate_inverseClock(t *testing.T) {
+	// each destination pixel is sampled once, no pixel is duplicated
+	in := `P1
+11 11
+00000100000
+00000100000
+00000100000
+00000100000
+00000100000
+00000100000
+00000000000
+00000000000
+00000000000
+00000000000
+00000000000
+`
+	out := `P1
+11 11
+00000000000
+00000000000
+00000000100
+00000001000
+00000010000
+00000100000
+00000000000
+00000000000
+00000000000
+00000000000
+00000000000
+`
+	checkRotation(t, 45, in, out)
+}
+
+func TestRotate_inverseNoHoles(t *testing.T) {
+	// forward mapping leaves white holes inside rotated black area
+	in := `P1
+8 8
+11111111
+11111111
+11111111
+11111111
+11111111
+11111111
+11111111
+11111111
+`
+	out := `P1
+10 10
+0000110000
+0001011000
+0011111100
+0110110110
+1011011101
+1111111111
+0110110110
+0011111100
+0001011000
+0000110000
+`
+	checkRotation(t, 45, in, out, WithResize(), WithForwardMapping())
+
+	out = `P1
+10 10
+0000110000
+0001111000
+0011111100
+0111111110
+1111111111
+1111111111
+0111111110
+0011111100
+0001111000
+0000110000
+`
+	checkRotation(t, 45, in, out, WithResize())
+}
+
+func TestRotateGray_square(t *testing.T) {
+	// uncovered pixels are white
+	in := `P2
+3 3
+9
+1 2 3
+4 5 6
+7 8 9
+`
+	out := `P2
+3 3
+9
+7 4 1
+8 5 2
+9 6 3
+`
+	image, err := NewGrayImageFromString(in)
+	if err != nil {
+		t.Fatalf("unexpected parse error: %s", err)
+	}
+	image.Rotate(90)
+	writer := bytes.Buffer{}
+	if err := image.EncodeASCII(&writer); err != nil {
+		t.Fatalf("unexpected encode error: %s", err)
+	}
+	if writer.String() != out {
+		t.Fatalf("unexpected output: %s", writer.String())
+	}
+}
+
+func TestRotateColor_square(t *testing.T) {
+	// uncovered pixels are white
+	in := `P3
+2 2
+255
+255 0 0 0 255 0
+0 0 255 255 255 255
+`
+	out := `P3
+2 2
+255
+0 0 255 255 0 0
+255 255 255 0 255 0
+`
+	image, err := NewColorImageFromString(in)
+	if err != nil {
+		t.Fatalf("unexpected parse error: %s", err)
+	}
+	image.Rotate(90)
+	writer := bytes.Buffer{}
+	if err := image.EncodeASCII(&writer); err != nil {
+		t.Fatalf("unexpected encode error: %s", err)
+	}
+	if writer.String() != out {
+		t.Fatalf("unexpected output: %s", writer.String())
+	}
+}
+
+func TestRotate_resize(t *testing.T) {
+	// horizontal line becomes vertical instead of being cut
+	in := `P1
+3 1
+111
+`
+	out := `P1
+1 3
+1
+1
+1
+`
+	checkRotation(t, 90, in, out, WithResize())
+
+	// rotated corners fall outside source frame
+	in = `P1
+4 4
+1111
+1000
+1000
+1001
+`
+	out = `P1
+6 6
+001000
+001100
+010011
+100000
+000000
+000100
+`
+	checkRotation(t, 45, in, out, WithResize(), WithForwardMapping())
+
+	out = `P1
+6 6
+000000
+001100
+010010
+000000
+000000
+000000
+`
+	checkRotation(t, 45, in, out, WithResize())
+
+	// invariant rotation keeps size
+	checkRotation(t, 360, in, in, WithResize())
+}
+
+func TestRotate_resizeEmpty(t *testing.T) {
+	in := `P1
+0 0
+`
+	checkRotation(t, 45, in, in, WithResize())
+}
+
+func TestRotateColor_resizeTransparent(t *testing.T) {
+	// uncovered pixels of grown image are transparent
+	image := &ColorImage{2, 1, 255, []rgb{{1, 2, 3}, {4, 5, 6}}, []uint16{255, 255}}
+	image.Rotate(45, WithResize())
+	if image.width*image.height <= 2 || len(image.alpha) != image.width*image.height {
+		t.Fatalf("unexpected size %dx%d", image.width, image.height)
+	}
+	opaque := 0
+	for _, cAlpha := range image.alpha {
+		if cAlpha != 0 {
+			opaque++
+		}
+	}
+	if opaque != 2 {
+		t.Fatalf("unexpected alpha channel: %v", image.alpha)
+	}
 }
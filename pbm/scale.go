@@ -0,0 +1,301 @@
+// Copyright 2023 Xavier MARCELET. All rights reserved.
+// Use of this source code is governed by Apache
+// license that can be found in the LICENSE file.
+
+package pbm
+
+import (
+	"fmt"
+	"math"
+)
+
+// Filter - method used to compute pixels of scaled images
+type Filter int
+
+const (
+	// FilterNearest copies closest source pixel
+	FilterNearest Filter = iota
+	// FilterBox averages source pixels covered by destination pixel, bitmap
+	// pixels are black when at least half of covered area is black
+	FilterBox
+	// FilterEPX enlarges images with Scale2x/EPX algorithm which keeps edges
+	// of bitmaps sharp, by successive doublings, remaining factor is scaled
+	// with nearest neighbour
+	FilterEPX
+)
+
+// filterNames - names of filters, as accepted by ParseFilter
+var filterNames = []string{
+	FilterNearest: "nearest",
+	FilterBox:     "box",
+	FilterEPX:     "epx",
+}
+
+// ParseFilter returns filter of given name
+func ParseFilter(name string) (Filter, error) {
+	for cIdx, cName := range filterNames {
+		if cName == name {
+			return Filter(cIdx), nil
+		}
+	}
+	return FilterNearest, fmt.Errorf("unknown filter '%s', expecting 'nearest', 'box' or 'epx'", name)
+}
+
+// alphaPixel - pixel bundled with its alpha value, so that scaling decisions
+// apply to both
+type alphaPixel[T comparable] struct {
+	pixel T
+	alpha uint16
+}
+
+// zipAlpha bundles pixels with their alpha value, alpha is zero when image
+// has no alpha channel
+func zipAlpha[T comparable](data []T, alpha []uint16) []alphaPixel[T] {
+	result := make([]alphaPixel[T], len(data))
+	for cIdx := range data {
+		result[cIdx].pixel = data[cIdx]
+		if alpha != nil {
+			result[cIdx].alpha = alpha[cIdx]
+		}
+	}
+	return result
+}
+
+// unzipAlpha splits bundled pixels, alpha is nil when withAlpha is false
+func unzipAlpha[T comparable](zipped []alphaPixel[T], withAlpha bool) ([]T, []uint16) {
+	data := make([]T, len(zipped))
+	var alpha []uint16
+	if withAlpha {
+		alpha = make([]uint16, len(zipped))
+	}
+	for cIdx, cPixel := range zipped {
+		data[cIdx] = cPixel.pixel
+		if withAlpha {
+			alpha[cIdx] = cPixel.alpha
+		}
+	}
+	return data, alpha
+}
+
+// nearestPixels scales pixels copying source pixel closest to center of each
+// destination pixel, bands of rows are processed by given number of workers
+func nearestPixels[T any](data []T, width, height, newWidth, newHeight, workers int) []T {
+	result := make([]T, newWidth*newHeight)
+	parallelRows(newHeight, workers, func(top, bottom int) {
+		for y := top; y < bottom; y++ {
+			sourceY := (2*y + 1) * height / (2 * newHeight)
+			for x := 0; x < newWidth; x++ {
+				sourceX := (2*x + 1) * width / (2 * newWidth)
+				result[x+y*newWidth] = data[sourceX+sourceY*width]
+			}
+		}
+	})
+	return result
+}
+
+// coverage - part of a source row or column covered by a destination one
+type coverage struct {
+	index  int     // source row or column
+	weight float64 // covered length, in source pixels
+}
+
+// coverages computes, for each destination row or column, source ones it
+// covers
+//
+// Destination pixel i covers source interval [i*size/newSize, (i+1)*size/newSize).
+func coverages(size, newSize int) [][]coverage {
+	result := make([][]coverage, newSize)
+	ratio := float64(size) / float64(newSize)
+	for cIdx := range result {
+		start, end := float64(cIdx)*ratio, float64(cIdx+1)*ratio
+		for source := int(start); source < size && float64(source) < end; source++ {
+			weight := math.Min(end, float64(source+1)) - math.Max(start, float64(source))
+			if weight > 0 {
+				result[cIdx] = append(result[cIdx], coverage{source, weight})
+			}
+		}
+	}
+	return result
+}
+
+// boxPixels scales pixels combining source pixels covered by each destination
+// pixel with their covered area as weight, bands of rows are processed by
+// given number of workers
+//
+//  1. each band of rows has its own buffers of covered pixels
+func boxPixels[T any](data []T, width, height, newWidth, newHeight, workers int, combine func(pixels []T, weights []float64) T) []T {
+	var (
+		result  = make([]T, newWidth*newHeight)
+		columns = coverages(width, newWidth)
+		rows    = coverages(height, newHeight)
+	)
+	parallelRows(newHeight, workers, func(top, bottom int) {
+		// 1.
+		var (
+			pixels  []T
+			weights []float64
+		)
+		for y := top; y < bottom; y++ {
+			for x, cColumn := range columns {
+				pixels, weights = pixels[:0], weights[:0]
+				for _, cY := range rows[y] {
+					for _, cX := range cColumn {
+						pixels = append(pixels, data[cX.index+cY.index*width])
+						weights = append(weights, cX.weight*cY.weight)
+					}
+				}
+				result[x+y*newWidth] = combine(pixels, weights)
+			}
+		}
+	})
+	return result
+}
+
+// epxPixels doubles image size with EPX algorithm
+//
+// Each pixel P becomes four pixels, a corner takes the color of its two
+// neighbours of P when they match and other neighbours differ. Out of bound
+// neighbours are P itself. Bands of rows are processed by given number of
+// workers.
+//
+//	  A        1 2
+//	C P B  ->  3 4
+//	  D
+func epxPixels[T comparable](data []T, width, height, workers int) []T {
+	result := make([]T, width*height*4)
+	parallelRows(height, workers, func(top, bottom int) {
+		epxRows(result, data, width, height, top, bottom)
+	})
+	return result
+}
+
+// epxRows writes doubled source rows from top to bottom to result, see
+// epxPixels
+func epxRows[T comparable](result, data []T, width, height, top, bottom int) {
+	at := func(x, y int, fallback T) T {
+		if x < 0 || y < 0 || x >= width || y >= height {
+			return fallback
+		}
+		return data[x+y*width]
+	}
+	for y := top; y < bottom; y++ {
+		for x := 0; x < width; x++ {
+			p := data[x+y*width]
+			a, b, c, d := at(x, y-1, p), at(x+1, y, p), at(x-1, y, p), at(x, y+1, p)
+			one, two, three, four := p, p, p, p
+			if c == a && c != d && a != b {
+				one = a
+			}
+			if a == b && a != c && b != d {
+				two = b
+			}
+			if d == c && d != b && c != a {
+				three = c
+			}
+			if b == d && b != a && d != c {
+				four = d
+			}
+			row := 2 * width
+			result[2*x+2*y*row] = one
+			result[2*x+1+2*y*row] = two
+			result[2*x+(2*y+1)*row] = three
+			result[2*x+1+(2*y+1)*row] = four
+		}
+	}
+}
+
+// scalePixels returns pixels of image scaled to given size with given filter,
+// by given number of workers
+func scalePixels[T comparable](data []T, width, height, newWidth, newHeight int, filter Filter, workers int, combine func([]T, []float64) T) []T {
+	switch filter {
+	case FilterBox:
+		return boxPixels(data, width, height, newWidth, newHeight, workers, combine)
+	case FilterEPX:
+		for width > 0 && height > 0 && 2*width <= newWidth && 2*height <= newHeight {
+			data = epxPixels(data, width, height, workers)
+			width, height = 2*width, 2*height
+		}
+	}
+	return nearestPixels(data, width, height, newWidth, newHeight, workers)
+}
+
+// checkScale validates scaling parameters
+func checkScale(img Sizer, newWidth, newHeight int, filter Filter) error {
+	if newWidth < 0 || newHeight < 0 {
+		return fmt.Errorf("invalid size '%dx%d', expecting positive dimensions", newWidth, newHeight)
+	}
+	if (img.Width() == 0 || img.Height() == 0) && newWidth*newHeight != 0 {
+		return fmt.Errorf("invalid size '%dx%d', empty image can't be enlarged", newWidth, newHeight)
+	}
+	if filter < FilterNearest || filter > FilterEPX {
+		return fmt.Errorf("invalid filter '%d'", int(filter))
+	}
+	return nil
+}
+
+// average computes weighted mean of given values
+func average(values []float64, weights []float64) uint16 {
+	var sum, total float64
+	for cIdx, cValue := range values {
+		sum += cValue * weights[cIdx]
+		total += weights[cIdx]
+	}
+	return uint16(math.Round(sum / total))
+}
+
+// Scale resizes image to given dimensions with given filter, only WithWorkers
+// option applies
+func (i *Image) Scale(newWidth, newHeight int, filter Filter, opts ...Option) error {
+	if err := checkScale(i, newWidth, newHeight, filter); err != nil {
+		return err
+	}
+	*i = *scaleBits(i, newWidth, newHeight, filter, newOptions(opts).workers)
+	return nil
+}
+
+// Scale resizes image to given dimensions with given filter, box filter
+// averages samples and alpha values, only WithWorkers option applies
+func (g *GrayImage) Scale(newWidth, newHeight int, filter Filter, opts ...Option) error {
+	if err := checkScale(g, newWidth, newHeight, filter); err != nil {
+		return err
+	}
+	mean := func(pixels []alphaPixel[uint16], weights []float64) alphaPixel[uint16] {
+		values, alpha := make([]float64, len(pixels)), make([]float64, len(pixels))
+		for cIdx, cPixel := range pixels {
+			values[cIdx], alpha[cIdx] = float64(cPixel.pixel), float64(cPixel.alpha)
+		}
+		return alphaPixel[uint16]{average(values, weights), average(alpha, weights)}
+	}
+	zipped := scalePixels(zipAlpha(g.data, g.alpha), g.width, g.height, newWidth, newHeight, filter, newOptions(opts).workers, mean)
+	g.data, g.alpha = unzipAlpha(zipped, g.HasAlpha())
+	g.width, g.height = newWidth, newHeight
+	return nil
+}
+
+// Scale resizes image to given dimensions with given filter, box filter
+// averages each channel and alpha values, only WithWorkers option applies
+func (c *ColorImage) Scale(newWidth, newHeight int, filter Filter, opts ...Option) error {
+	if err := checkScale(c, newWidth, newHeight, filter); err != nil {
+		return err
+	}
+	mean := func(pixels []alphaPixel[rgb], weights []float64) alphaPixel[rgb] {
+		channels := [4][]float64{}
+		for cChannel := range channels {
+			channels[cChannel] = make([]float64, len(pixels))
+		}
+		for cIdx, cPixel := range pixels {
+			channels[0][cIdx] = float64(cPixel.pixel.r)
+			channels[1][cIdx] = float64(cPixel.pixel.g)
+			channels[2][cIdx] = float64(cPixel.pixel.b)
+			channels[3][cIdx] = float64(cPixel.alpha)
+		}
+		return alphaPixel[rgb]{
+			rgb{average(channels[0], weights), average(channels[1], weights), average(channels[2], weights)},
+			average(channels[3], weights),
+		}
+	}
+	zipped := scalePixels(zipAlpha(c.data, c.alpha), c.width, c.height, newWidth, newHeight, filter, newOptions(opts).workers, mean)
+	c.data, c.alpha = unzipAlpha(zipped, c.HasAlpha())
+	c.width, c.height = newWidth, newHeight
+	return nil
+}
@@ -0,0 +1,121 @@
+// Copyright 2023 Xavier MARCELET. All rights reserved.
+// Use of this source code is governed by Apache
+// license that can be found in the LICENSE file.
+
+package pbm
+
+import (
+	"testing"
+)
+
+// scaling gives operation scaling to given dimensions with given filter
+func scaling(width, height int, filter Filter) func(*Image) error {
+	return func(img *Image) error { return img.Scale(width, height, filter) }
+}
+
+func TestScale_nearest(t *testing.T) {
+	in := `P1
+3 3
+010
+111
+010
+`
+	out := `P1
+6 6
+001100
+001100
+111111
+111111
+001100
+001100
+`
+	checkOperation(t, in, out, scaling(6, 6, FilterNearest))
+	checkOperation(t, out, in, scaling(3, 3, FilterNearest))
+}
+
+func TestScale_box(t *testing.T) {
+	in := `P1
+4 4
+1100
+1100
+0011
+0011
+`
+	out := `P1
+2 2
+10
+01
+`
+	checkOperation(t, in, out, scaling(2, 2, FilterBox))
+
+	// half covered pixels are black
+	in = `P1
+3 2
+100
+011
+`
+	out = `P1
+2 1
+11
+`
+	checkOperation(t, in, out, scaling(2, 1, FilterBox))
+}
+
+func TestScale_epx(t *testing.T) {
+	in := `P1
+3 3
+010
+111
+010
+`
+	out := `P1
+6 6
+001100
+011110
+111111
+111111
+011110
+001100
+`
+	checkOperation(t, in, out, scaling(6, 6, FilterEPX))
+
+	// remaining factor uses nearest neighbour
+	in = `P1
+3 2
+100
+011
+`
+	out = `P1
+7 3
+1100000
+0011111
+0011111
+`
+	checkOperation(t, in, out, scaling(7, 3, FilterEPX))
+}
+
+func TestScaleGray_box(t *testing.T) {
+	image := &GrayImage{2, 1, 255, []uint16{10, 21}, []uint16{0, 255}}
+	if err := image.Scale(1, 1, FilterBox); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if image.data[0] != 16 || image.alpha[0] != 128 {
+		t.Fatalf("unexpected pixels %v, alpha %v", image.data, image.alpha)
+	}
+}
+
+func TestScale_invalid(t *testing.T) {
+	img, err := NewImageFromString("P1\n0 0\n")
+	if err != nil {
+		t.Fatalf("unexpected parse error: %s", err)
+	}
+	if err := img.Scale(2, 2, FilterNearest); err == nil {
+		t.Fatalf("expected error")
+	}
+	if err := img.Scale(-1, 2, FilterNearest); err == nil {
+		t.Fatalf("expected error")
+	}
+	if _, err := ParseFilter("bicubic"); err == nil {
+		t.Fatalf("expected error")
+	}
+}
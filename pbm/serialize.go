@@ -5,42 +5,66 @@
 package pbm
 
 import (
+	"encoding/binary"
 	"fmt"
 	"io"
 	"os"
+	"strconv"
 )
 
-// Serialize image into file in ascii/plain representation
-func (i *Image) EncodeASCIIToFile(path string) error {
-	var writer io.Writer
-
+// encodeToFile opens given file path, '-' meaning stdout, and calls encode on it
+//
+//  1. close error is reported since it may hide a failed write
+func encodeToFile(path string, encode func(io.Writer) error) error {
 	if path == "-" {
-		writer = os.Stdout
-	} else {
-		flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
-		file, err := os.OpenFile(path, flags, 0644)
-		if err != nil {
-			return err
-		}
-		writer = file
+		return encode(os.Stdout)
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	file, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return err
 	}
-	return i.EncodeASCII(writer)
+	if err := encode(file); err != nil {
+		file.Close()
+		return err
+	}
+	// 1.
+	return file.Close()
+}
+
+// Serialize image into file in ascii/plain representation
+func (i *Image) EncodeASCIIToFile(path string) error {
+	return encodeToFile(path, i.EncodeASCII)
 }
 
 // Serialize image into stream in ascii/plain representation
 func (i *Image) EncodeASCII(stream io.Writer) error {
-	if err := i.encodeASCIIHeader(stream); err != nil {
+	if err := encodeHeader(stream, PBMMagicP1, i); err != nil {
 		return err
 	}
 	return i.encodeASCIIData(stream)
 }
 
-func (i *Image) encodeASCIIHeader(stream io.Writer) error {
-	_, err := fmt.Fprintf(stream, "%s\n", PBMMagicP1)
+// Serialize image into file in binary/raw representation
+func (i *Image) EncodeBinaryToFile(path string) error {
+	return encodeToFile(path, i.EncodeBinary)
+}
+
+// Serialize image into stream in binary/raw representation
+func (i *Image) EncodeBinary(stream io.Writer) error {
+	if err := encodeHeader(stream, PBMMagicP4, i); err != nil {
+		return err
+	}
+	return i.encodeBinaryData(stream)
+}
+
+func encodeHeader(stream io.Writer, magic string, image Sizer) error {
+	_, err := fmt.Fprintf(stream, "%s\n", magic)
 	if err != nil {
 		return err
 	}
-	_, err = fmt.Fprintf(stream, "%d %d\n", i.Width(), i.Height())
+	_, err = fmt.Fprintf(stream, "%d %d\n", image.Width(), image.Height())
 	if err != nil {
 		return err
 	}
@@ -53,26 +77,169 @@ func (i *Image) encodeASCIIHeader(stream io.Writer) error {
 // for performance considerations
 //
 //  1. allocates image-size plus enough room for newlines written every "width" bytes
-//  2. cOffset tracks how many separator were written so far which affects byte index
-//     to write in result variable
+//  2. most significant bit of word is next pixel, '0' or '1' digit is
+//     computed without branching
 func (i *Image) encodeASCIIData(stream io.Writer) error {
 	var (
 		// 1.
 		result    = make([]byte, (i.Width()+1)*i.Height())
 		separator = byte(10)
 		white     = byte(48)
-		black     = byte(49)
 	)
-	for cOffset, cIdx := 0, 0; cIdx < i.Width()*i.Height(); cIdx++ {
-		if i.data[cIdx] {
-			result[cIdx+cOffset] = black
+	for y := 0; y < i.Height(); y++ {
+		line := result[y*(i.Width()+1) : (y+1)*(i.Width()+1)]
+		for cIdx, cWord := range i.row(y) {
+			for x := cIdx * wordBits; x < (cIdx+1)*wordBits && x < i.Width(); x++ {
+				// 2.
+				line[x] = white + byte(cWord>>(wordBits-1))
+				cWord <<= 1
+			}
+		}
+		line[i.Width()] = separator
+	}
+	if _, err := stream.Write(result); err != nil {
+		return err
+	}
+	return nil
+}
+
+// serialize binary data section
+//
+// Pixels are packed 8 per byte, most significant bit first, each row being
+// padded to a full byte. This matches image words layout, which are copied
+// as is. As for ascii, data is serialized into memory first.
+func (i *Image) encodeBinaryData(stream io.Writer) error {
+	var (
+		rowBytes = (i.Width() + 7) / 8
+		result   = make([]byte, rowBytes*i.Height())
+	)
+	for y := 0; y < i.Height(); y++ {
+		packRow(result[y*rowBytes:(y+1)*rowBytes], i.row(y))
+	}
+	if _, err := stream.Write(result); err != nil {
+		return err
+	}
+	return nil
+}
+
+// packRow copies words of an image row to bytes of given line
+//
+//  1. padding bits of words are zero, last word of row may be truncated
+func packRow(line []byte, row []uint64) {
+	for cIdx, cWord := range row {
+		bytes := line[cIdx*8:]
+		if len(bytes) >= 8 {
+			binary.BigEndian.PutUint64(bytes, cWord)
+			continue
+		}
+		// 1.
+		for cByte := range bytes {
+			bytes[cByte] = byte(cWord >> (56 - 8*cByte))
+		}
+	}
+}
+
+// Serialize image into file in ascii/plain representation
+func (g *GrayImage) EncodeASCIIToFile(path string) error {
+	return encodeToFile(path, g.EncodeASCII)
+}
+
+// Serialize image into stream in ascii/plain representation
+func (g *GrayImage) EncodeASCII(stream io.Writer) error {
+	if err := encodeMaxvalHeader(stream, PGMMagicP2, g, g.maxval); err != nil {
+		return err
+	}
+	return encodeSamples(stream, g.data, g.width)
+}
+
+// Serialize image into file in binary/raw representation
+func (g *GrayImage) EncodeBinaryToFile(path string) error {
+	return encodeToFile(path, g.EncodeBinary)
+}
+
+// Serialize image into stream in binary/raw representation
+func (g *GrayImage) EncodeBinary(stream io.Writer) error {
+	if err := encodeMaxvalHeader(stream, PGMMagicP5, g, g.maxval); err != nil {
+		return err
+	}
+	return encodeBinarySamples(stream, g.data, g.maxval)
+}
+
+// Serialize image into file in ascii/plain representation
+func (c *ColorImage) EncodeASCIIToFile(path string) error {
+	return encodeToFile(path, c.EncodeASCII)
+}
+
+// Serialize image into stream in ascii/plain representation
+func (c *ColorImage) EncodeASCII(stream io.Writer) error {
+	if err := encodeMaxvalHeader(stream, PPMMagicP3, c, c.maxval); err != nil {
+		return err
+	}
+	return encodeSamples(stream, c.samples(), c.width*3)
+}
+
+// Serialize image into file in binary/raw representation
+func (c *ColorImage) EncodeBinaryToFile(path string) error {
+	return encodeToFile(path, c.EncodeBinary)
+}
+
+// Serialize image into stream in binary/raw representation
+func (c *ColorImage) EncodeBinary(stream io.Writer) error {
+	if err := encodeMaxvalHeader(stream, PPMMagicP6, c, c.maxval); err != nil {
+		return err
+	}
+	return encodeBinarySamples(stream, c.samples(), c.maxval)
+}
+
+// samples flattens pixels into red, green and blue samples
+func (c *ColorImage) samples() []uint16 {
+	samples := make([]uint16, 0, len(c.data)*3)
+	for _, cPixel := range c.data {
+		samples = append(samples, cPixel.r, cPixel.g, cPixel.b)
+	}
+	return samples
+}
+
+// encodeMaxvalHeader writes header of formats having a maximum sample value
+func encodeMaxvalHeader(stream io.Writer, magic string, image Sizer, maxval int) error {
+	if err := encodeHeader(stream, magic, image); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(stream, "%d\n", maxval)
+	return err
+}
+
+// serialize ascii data section, one row of rowLength samples per line
+//
+//  1. samples are separated by a space, last one of the row by a newline
+func encodeSamples(stream io.Writer, samples []uint16, rowLength int) error {
+	result := make([]byte, 0, len(samples)*4)
+	for cIdx, cSample := range samples {
+		result = strconv.AppendUint(result, uint64(cSample), 10)
+		// 1.
+		if (cIdx+1)%rowLength == 0 {
+			result = append(result, '\n')
 		} else {
-			result[cIdx+cOffset] = white
+			result = append(result, ' ')
 		}
-		// 2.
-		if (cIdx+1)%i.Width() == 0 {
-			cOffset++
-			result[cIdx+cOffset] = separator
+	}
+	if _, err := stream.Write(result); err != nil {
+		return err
+	}
+	return nil
+}
+
+// serialize binary data section, samples are written on two bytes, most
+// significant first, when maxval doesn't fit in a single byte
+func encodeBinarySamples(stream io.Writer, samples []uint16, maxval int) error {
+	size := sampleSize(maxval)
+	result := make([]byte, len(samples)*size)
+	for cIdx, cSample := range samples {
+		if size == 2 {
+			result[cIdx*2] = byte(cSample >> 8)
+			result[cIdx*2+1] = byte(cSample)
+		} else {
+			result[cIdx] = byte(cSample)
 		}
 	}
 	if _, err := stream.Write(result); err != nil {
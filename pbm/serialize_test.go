@@ -5,6 +5,7 @@
 package pbm
 
 import (
+	"bytes"
 	"os"
 	"path/filepath"
 	"strings"
@@ -13,7 +14,7 @@ import (
 
 func TestSerialize_simple(t *testing.T) {
 	// simple test
-	image := Image{2, 2, []bool{true, true, false, false}}
+	image := newImageFromPixels(2, 2, []bool{true, true, false, false})
 	expect := `P1
 2 2
 11
@@ -31,7 +32,7 @@ func TestSerialize_simple(t *testing.T) {
 
 func TestSerialize_closeWriter(t *testing.T) {
 	// output to closed writer
-	image := Image{2, 2, []bool{true, true, false, false}}
+	image := newImageFromPixels(2, 2, []bool{true, true, false, false})
 	file, _ := os.CreateTemp("dir", "prefix")
 	file.Close()
 	err := image.EncodeASCII(file)
@@ -42,11 +43,11 @@ func TestSerialize_closeWriter(t *testing.T) {
 
 func TestSerialize_writeFile(t *testing.T) {
 	// actually write to file
-	image := Image{3, 3, []bool{
+	image := newImageFromPixels(3, 3, []bool{
 		true, true, true,
 		false, false, false,
 		true, false, true,
-	}}
+	})
 	expect := `P1
 3 3
 111
@@ -72,3 +73,166 @@ func TestSerialize_writeFile(t *testing.T) {
 		t.Fatalf("unexpected serialization output: %v, want %v", output, []byte(expect))
 	}
 }
+
+func TestSerialize_binary(t *testing.T) {
+	// rows padded to full bytes
+	image := newImageFromPixels(10, 2, []bool{
+		true, false, false, false, false, false, false, false, true, true,
+		false, true, false, false, false, false, false, false, false, false,
+	})
+	expect := "P4\n10 2\n\x80\xc0\x40\x00"
+	content := new(strings.Builder)
+	err := image.EncodeBinary(content)
+	if err != nil {
+		t.Fatalf("unexpected serialization error: %s", err)
+	}
+	if expect != content.String() {
+		t.Fatalf("unexpected serialization output: %v, want %v", []byte(content.String()), []byte(expect))
+	}
+}
+
+func TestSerialize_binaryRoundTrip(t *testing.T) {
+	// decode what was encoded
+	in := `P1
+9 3
+100000001
+011111110
+101010101
+`
+	image, err := NewImageFromString(in)
+	if err != nil {
+		t.Fatalf("unexpected parse error: %s", err)
+	}
+	binary := bytes.Buffer{}
+	if err := image.EncodeBinary(&binary); err != nil {
+		t.Fatalf("unexpected serialization error: %s", err)
+	}
+	decoded, err := NewImageFromString(binary.String())
+	if err != nil {
+		t.Fatalf("unexpected parse error: %s", err)
+	}
+	ascii := bytes.Buffer{}
+	if err := decoded.EncodeASCII(&ascii); err != nil {
+		t.Fatalf("unexpected serialization error: %s", err)
+	}
+	if ascii.String() != in {
+		t.Fatalf("unexpected round-trip output: %s", ascii.String())
+	}
+}
+
+func TestSerialize_writeBinaryFile(t *testing.T) {
+	// actually write to file
+	image := newImageFromPixels(3, 3, []bool{
+		true, true, true,
+		false, false, false,
+		true, false, true,
+	})
+	expect := "P4\n3 3\n\xe0\x00\xa0"
+	dir, err := os.MkdirTemp("", "test")
+	if err != nil {
+		t.Fatalf("could not create temp directory")
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "output")
+	err = image.EncodeBinaryToFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	output, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("could not read generated file path '%s': %s", path, err)
+	}
+	if string(output) != expect {
+		t.Fatalf("unexpected serialization output: %v, want %v", output, []byte(expect))
+	}
+}
+
+func TestSerializeGray_ascii(t *testing.T) {
+	image := GrayImage{3, 2, 255, []uint16{0, 10, 255, 128, 64, 32}, nil}
+	expect := `P2
+3 2
+255
+0 10 255
+128 64 32
+`
+	content := new(strings.Builder)
+	if err := image.EncodeASCII(content); err != nil {
+		t.Fatalf("unexpected serialization error: %s", err)
+	}
+	if expect != content.String() {
+		t.Fatalf("unexpected serialization output: %s, want %s", content.String(), expect)
+	}
+}
+
+func TestSerializeGray_binary(t *testing.T) {
+	image := GrayImage{2, 1, 255, []uint16{0, 255}, nil}
+	expect := "P5\n2 1\n255\n\x00\xff"
+	content := new(strings.Builder)
+	if err := image.EncodeBinary(content); err != nil {
+		t.Fatalf("unexpected serialization error: %s", err)
+	}
+	if expect != content.String() {
+		t.Fatalf("unexpected serialization output: %v, want %v", []byte(content.String()), []byte(expect))
+	}
+
+	image = GrayImage{2, 1, 1023, []uint16{258, 1023}, nil}
+	expect = "P5\n2 1\n1023\n\x01\x02\x03\xff"
+	content = new(strings.Builder)
+	if err := image.EncodeBinary(content); err != nil {
+		t.Fatalf("unexpected serialization error: %s", err)
+	}
+	if expect != content.String() {
+		t.Fatalf("unexpected serialization output: %v, want %v", []byte(content.String()), []byte(expect))
+	}
+}
+
+func TestSerializeGray_writeFile(t *testing.T) {
+	image := GrayImage{2, 2, 65535, []uint16{0, 1, 65534, 65535}, nil}
+	dir, err := os.MkdirTemp("", "test")
+	if err != nil {
+		t.Fatalf("could not create temp directory")
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "output")
+	if err := image.EncodeBinaryToFile(path); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	decoded, err := NewGrayImageFromFile(path)
+	expectGray(t, decoded, err, 2, 2, 65535, image.data)
+
+	if err := image.EncodeASCIIToFile(path); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	decoded, err = NewGrayImageFromFile(path)
+	expectGray(t, decoded, err, 2, 2, 65535, image.data)
+}
+
+func TestSerializeColor_ascii(t *testing.T) {
+	image := ColorImage{2, 1, 255, []rgb{{255, 0, 0}, {0, 16, 32}}, nil}
+	expect := `P3
+2 1
+255
+255 0 0 0 16 32
+`
+	content := new(strings.Builder)
+	if err := image.EncodeASCII(content); err != nil {
+		t.Fatalf("unexpected serialization error: %s", err)
+	}
+	if expect != content.String() {
+		t.Fatalf("unexpected serialization output: %s, want %s", content.String(), expect)
+	}
+}
+
+func TestSerializeColor_binary(t *testing.T) {
+	image := ColorImage{1, 1, 65535, []rgb{{65535, 256, 2}}, nil}
+	expect := "P6\n1 1\n65535\n\xff\xff\x01\x00\x00\x02"
+	content := new(strings.Builder)
+	if err := image.EncodeBinary(content); err != nil {
+		t.Fatalf("unexpected serialization error: %s", err)
+	}
+	if expect != content.String() {
+		t.Fatalf("unexpected serialization output: %v, want %v", []byte(content.String()), []byte(expect))
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2023 Xavier MARCELET. All rights reserved.
+// Use of this source code is governed by Apache
+// license that can be found in the LICENSE file.
+
+package pbm
+
+import (
+	"image"
+	"math"
+)
+
+// Shearer computes rotated coordinates for a given (x,y) point using three
+// successive shears (Paeth's method)
+//
+// Each shear shifts whole rows or columns by an integer number of pixels, the
+// resulting mapping is a bijection of pixel coordinates: no hole nor duplicate
+// pixel is created and rotation can be reverted exactly. Shears are precise for
+// angles between -45 and 45 degrees, larger angles should be reduced first with
+// exact quarter turns.
+type Shearer struct {
+	width  int     // width of source image
+	height int     // height of source image
+	x0     float64 // x coordinates of center of rotation
+	y0     float64 // y coordinates of center of rotation
+	alpha  float64 // factor of horizontal shears, -tan(angle/2)
+	beta   float64 // factor of vertical shear, sin(angle)
+}
+
+// NewShearer initializes shearer object
+//
+// Center of rotation is the geometric center of image unless WithCenter,
+// WithAnchor or WithCentroid option is given.
+func NewShearer(angle float64, img Sizer, opts ...Option) *Shearer {
+	θ := angle * (math.Pi / float64(180))
+	x0, y0 := newOptions(opts).pivot(img)
+	return &Shearer{
+		width:  img.Width(),
+		height: img.Height(),
+		x0:     x0,
+		y0:     y0,
+		alpha:  -math.Tan(θ / 2),
+		beta:   math.Sin(θ),
+	}
+}
+
+// shift gives integer offset applied by a shear of given factor to a row or
+// column at given distance of center
+func shift(factor float64, distance float64) int {
+	return int(math.Round(factor * distance))
+}
+
+// Compute computes pixel new coordinates after rotation
+//
+// Horizontal, vertical then horizontal shear.
+func (s *Shearer) Compute(x int, y int) (int, int) {
+	x += shift(s.alpha, float64(y)-s.y0)
+	y += shift(s.beta, float64(x)-s.x0)
+	x += shift(s.alpha, float64(y)-s.y0)
+	return x, y
+}
+
+// Inverse computes source pixel coordinates of a pixel after rotation
+//
+// Shears of Compute are reverted in opposite order, result is exact.
+func (s *Shearer) Inverse(x int, y int) (int, int) {
+	x -= shift(s.alpha, float64(y)-s.y0)
+	y -= shift(s.beta, float64(x)-s.x0)
+	x -= shift(s.alpha, float64(y)-s.y0)
+	return x, y
+}
+
+// Bounds computes the smallest rectangle holding all rotated pixels
+//
+// For angles between -45 and 45 degrees, rotated x and y coordinates are
+// monotonic along rows, extreme values are reached on first or last column.
+func (s *Shearer) Bounds() image.Rectangle {
+	var bounds image.Rectangle
+	if s.width == 0 || s.height == 0 {
+		return bounds
+	}
+	for y := 0; y < s.height; y++ {
+		for _, x := range []int{0, s.width - 1} {
+			pixelX, pixelY := s.Compute(x, y)
+			pixel := image.Rect(pixelX, pixelY, pixelX+1, pixelY+1)
+			if bounds.Empty() {
+				bounds = pixel
+			}
+			bounds = bounds.Union(pixel)
+		}
+	}
+	return bounds
+}
+
+// Frame gives rectangle of given size which point (x,y) matches center of
+// rotation
+//
+// When center of rotation falls between pixels of frame, frame is shifted by
+// half a pixel toward top-left corner.
+func (s *Shearer) Frame(width, height int, x, y float64) image.Rectangle {
+	offset := image.Point{
+		int(math.Floor(s.x0 - x + 0.5)),
+		int(math.Floor(s.y0 - y + 0.5)),
+	}
+	return image.Rect(0, 0, width, height).Add(offset)
+}
@@ -0,0 +1,111 @@
+// Copyright 2023 Xavier MARCELET. All rights reserved.
+// Use of this source code is governed by Apache
+// license that can be found in the LICENSE file.
+
+package pbm
+
+import (
+	"testing"
+)
+
+func TestShearer_reversible(t *testing.T) {
+	for _, cAngle := range []float64{-45, -30, -1, 1, 12.5, 30, 45} {
+		shearer := NewShearer(cAngle, &Image{width: 7, height: 4})
+		for y := -10; y < 10; y++ {
+			for x := -10; x < 10; x++ {
+				rotatedX, rotatedY := shearer.Compute(x, y)
+				if sourceX, sourceY := shearer.Inverse(rotatedX, rotatedY); sourceX != x || sourceY != y {
+					t.Fatalf("angle %f: (%d,%d) reverted to (%d,%d)", cAngle, x, y, sourceX, sourceY)
+				}
+			}
+		}
+	}
+}
+
+func TestRotate_shear(t *testing.T) {
+	in := `P1
+11 11
+00000100000
+00000100000
+00000100000
+00000100000
+00000100000
+00000100000
+00000000000
+00000000000
+00000000000
+00000000000
+00000000000
+`
+	out := `P1
+11 11
+00000000000
+00000000100
+00000000100
+00000001000
+00000011000
+00000100000
+00000000000
+00000000000
+00000000000
+00000000000
+00000000000
+`
+	checkRotation(t, 45, in, out, WithAlgorithm(AlgorithmShear))
+
+	// larger angles start with a quarter turn
+	out = `P1
+11 11
+00000000000
+00000000000
+00000000000
+00000000000
+00000000000
+00000100000
+00000110000
+00000001000
+00000000100
+00000000010
+00000000000
+`
+	checkRotation(t, 135, in, out, WithAlgorithm(AlgorithmShear))
+
+	// rotated corners fall outside source frame
+	in = `P1
+4 4
+1111
+1000
+1000
+1001
+`
+	out = `P1
+6 4
+011100
+010011
+100000
+000100
+`
+	checkRotation(t, 45, in, out, WithAlgorithm(AlgorithmShear), WithResize())
+}
+
+func TestRotate_shearPreservesPixels(t *testing.T) {
+	in := `P1
+7 5
+1011001
+0110111
+1111000
+0001101
+1100110
+`
+	for _, cAngle := range []float64{10, 45, 100, -170, 269} {
+		img, err := NewImageFromString(in)
+		if err != nil {
+			t.Fatalf("unexpected parse error: %s", err)
+		}
+		expected := img.count()
+		img.Rotate(cAngle, WithAlgorithm(AlgorithmShear), WithResize())
+		if count := img.count(); count != expected {
+			t.Fatalf("angle %f: got %d black pixels, expected %d", cAngle, count, expected)
+		}
+	}
+}
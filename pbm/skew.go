@@ -0,0 +1,98 @@
+// Copyright 2023 Xavier MARCELET. All rights reserved.
+// Use of this source code is governed by Apache
+// license that can be found in the LICENSE file.
+
+package pbm
+
+import (
+	"image"
+	"math"
+)
+
+const (
+	// maxSkewAngle - largest skew detected by DetectSkew, in degrees
+	maxSkewAngle = 45.0
+	// maxSkewPoints - black pixels sampled by DetectSkew on large images
+	maxSkewPoints = 100000
+)
+
+// projection computes the projection profile score of given points for a
+// candidate skew angle
+//
+// Points are undone from candidate rotation and counted per row, the sum of
+// squared counts is the largest when text lines fall in the fewest rows.
+func projection(points []image.Point, angle float64, rows []int, offset int) float64 {
+	θ := angle * (math.Pi / float64(180))
+	sinθ, cosθ := math.Sin(θ), math.Cos(θ)
+	for cIdx := range rows {
+		rows[cIdx] = 0
+	}
+	for _, cPoint := range points {
+		row := int(math.Round(-sinθ*float64(cPoint.X)+cosθ*float64(cPoint.Y))) + offset
+		rows[row]++
+	}
+	score := 0.0
+	for _, cCount := range rows {
+		score += float64(cCount) * float64(cCount)
+	}
+	return score
+}
+
+// DetectSkew estimates the dominant skew angle of image content, rotating
+// image by the opposite angle straightens it
+//
+// Detection uses projection profiles: rows of text or lines give sharp
+// profile peaks for their actual angle. Candidate angles between -45 and 45
+// degrees are tried by steps of one degree, then best one is refined by steps
+// of a tenth of a degree. Confidence is between 0, for blank or uniform
+// images, and 1 when best profile stands out.
+//
+//  1. sample black pixels of large images
+//  2. rows of rotated points never exceed image dimensions
+//  3. refined candidates are counted in tenths so that float error never skips
+//     one, and don't exceed largest detected skew
+//  4. confidence compares best score to mean score of coarse candidates
+func (i *Image) DetectSkew() (float64, float64) {
+	count := i.count()
+	if count == 0 {
+		return 0, 0
+	}
+
+	// 1.
+	stride := count/maxSkewPoints + 1
+	points := make([]image.Point, 0, count/stride+1)
+	seen := 0
+	i.blackPixels(func(x, y int) {
+		if seen%stride == 0 {
+			points = append(points, image.Point{x, y})
+		}
+		seen++
+	})
+
+	// 2.
+	offset := i.width + i.height
+	rows := make([]int, 2*offset+1)
+
+	best, bestScore, total, tries := 0.0, -1.0, 0.0, 0
+	for angle := -maxSkewAngle; angle <= maxSkewAngle; angle++ {
+		score := projection(points, angle, rows, offset)
+		total += score
+		tries++
+		if score > bestScore {
+			best, bestScore = angle, score
+		}
+	}
+	coarse := best
+	// 3.
+	for step := -10; step <= 10; step++ {
+		angle := math.Max(-maxSkewAngle, math.Min(maxSkewAngle, coarse+float64(step)/10))
+		score := projection(points, angle, rows, offset)
+		if score > bestScore {
+			best, bestScore = angle, score
+		}
+	}
+
+	// 4.
+	confidence := 1 - total/float64(tries)/bestScore
+	return math.Round(best*10) / 10, confidence
+}
@@ -0,0 +1,66 @@
+// Copyright 2023 Xavier MARCELET. All rights reserved.
+// Use of this source code is governed by Apache
+// license that can be found in the LICENSE file.
+
+package pbm
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+// linesImage returns a square image of given size holding horizontal lines
+func linesImage(t *testing.T, size int) *Image {
+	t.Helper()
+
+	rows := []string{"P1", fmt.Sprintf("%d %d", size, size)}
+	for y := 0; y < size; y++ {
+		if y%12 == 3 {
+			rows = append(rows, strings.Repeat("1", size))
+		} else {
+			rows = append(rows, strings.Repeat("0", size))
+		}
+	}
+	img, err := NewImageFromString(strings.Join(rows, "\n"))
+	if err != nil {
+		t.Fatalf("unexpected parse error: %s", err)
+	}
+	return img
+}
+
+func TestDetectSkew(t *testing.T) {
+	for _, cAngle := range []float64{0, 3, -7.5, 20} {
+		img := linesImage(t, 200)
+		img.Rotate(cAngle)
+		skew, confidence := img.DetectSkew()
+		if math.Abs(skew-cAngle) > 0.5 {
+			t.Fatalf("detected skew %f of %f rotation", skew, cAngle)
+		}
+		if confidence < 0.5 {
+			t.Fatalf("unexpected confidence %f of %f rotation", confidence, cAngle)
+		}
+	}
+}
+
+func TestDetectSkew_blank(t *testing.T) {
+	img, err := NewImageFromString("P1\n2 2\n00\n00\n")
+	if err != nil {
+		t.Fatalf("unexpected parse error: %s", err)
+	}
+	if skew, confidence := img.DetectSkew(); skew != 0 || confidence != 0 {
+		t.Fatalf("unexpected skew %f with confidence %f", skew, confidence)
+	}
+}
+
+func TestDetectSkew_bounds(t *testing.T) {
+	// skew beyond largest detected one is reported at the bound
+	for _, cAngle := range []float64{45, -45, 46} {
+		img := linesImage(t, 200)
+		img.Rotate(cAngle)
+		if skew, _ := img.DetectSkew(); math.Abs(skew) > maxSkewAngle {
+			t.Fatalf("detected skew %f of %f rotation beyond %f", skew, cAngle, maxSkewAngle)
+		}
+	}
+}
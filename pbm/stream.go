@@ -0,0 +1,114 @@
+// Copyright 2023 Xavier MARCELET. All rights reserved.
+// Use of this source code is governed by Apache
+// license that can be found in the LICENSE file.
+
+package pbm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// Decoder reads successive images from a stream
+//
+// Netpbm formats allow several images to be concatenated in a single file or
+// stream, each one starting with its own magic number.
+type Decoder struct {
+	scanner   *bufio.Scanner
+	tokenizer *splitter
+	count     int // number of images found so far
+}
+
+// Creates Decoder object reading from given stream
+func NewDecoder(stream io.Reader) *Decoder {
+	tokenizer := &splitter{split: nextToken}
+	scanner := bufio.NewScanner(stream)
+	scanner.Split(tokenizer.Split)
+	return &Decoder{
+		scanner:   scanner,
+		tokenizer: tokenizer,
+	}
+}
+
+// Next decodes next PBM image of stream
+//
+// Returns io.EOF when stream holds no more image.
+func (d *Decoder) Next() (*Image, error) {
+	magic, err := d.magic(PBMMagicP1, PBMMagicP4)
+	if err != nil {
+		return nil, err
+	}
+	image := &Image{}
+	if err := image.decode(d, magic); err != nil {
+		return nil, err
+	}
+	return image, nil
+}
+
+// NextNetpbm decodes next image of stream, its type depends on magic number
+//
+// Returns io.EOF when stream holds no more image.
+func (d *Decoder) NextNetpbm() (Netpbm, error) {
+	magic, err := d.magic(PBMMagicP1, PBMMagicP4, PGMMagicP2, PGMMagicP5, PPMMagicP3, PPMMagicP6, PAMMagicP7)
+	if err != nil {
+		return nil, err
+	}
+
+	var image interface {
+		Netpbm
+		decode(d *Decoder, magic string) error
+	}
+	switch magic {
+	case PBMMagicP1, PBMMagicP4:
+		image = &Image{}
+	case PGMMagicP2, PGMMagicP5:
+		image = &GrayImage{}
+	case PPMMagicP3, PPMMagicP6:
+		image = &ColorImage{}
+	default:
+		return decodePAM(d)
+	}
+	if err := image.decode(d, magic); err != nil {
+		return nil, err
+	}
+	return image, nil
+}
+
+// Format selects the representation written by an Encoder
+type Format int
+
+// Supported encoder formats
+const (
+	FormatASCII  Format = iota // plain representation, P1, P2 or P3
+	FormatBinary               // raw representation, P4, P5 or P6
+	FormatPAM                  // arbitrary map representation, P7
+)
+
+// Encoder writes successive images to a stream
+type Encoder struct {
+	stream io.Writer
+	format Format
+}
+
+// Creates Encoder object writing images in given format to given stream
+func NewEncoder(stream io.Writer, format Format) *Encoder {
+	return &Encoder{
+		stream: stream,
+		format: format,
+	}
+}
+
+// Encode appends given image to stream
+func (e *Encoder) Encode(image Netpbm) error {
+	switch e.format {
+	case FormatASCII:
+		return image.EncodeASCII(e.stream)
+	case FormatBinary:
+		return image.EncodeBinary(e.stream)
+	case FormatPAM:
+		return image.EncodePAM(e.stream)
+	default:
+		return fmt.Errorf("unknown format '%d'", e.format)
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright 2023 Xavier MARCELET. All rights reserved.
+// Use of this source code is governed by Apache
+// license that can be found in the LICENSE file.
+
+package pbm
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDecoder_multiple(t *testing.T) {
+	// plain and raw images, with or without separators
+	input := "P1\n2 2\n1001\nP4 2 2 \x40\x80P1 1 1 1\n\nP1 3 1 0 1 0"
+	decoder := NewDecoder(strings.NewReader(input))
+	for _, want := range []string{"1001", "0110", "1", "010"} {
+		image, err := decoder.Next()
+		if err != nil {
+			t.Fatalf("unexpected decode error: %s", err)
+		}
+		pixels := image.pixels()
+		if len(pixels) != len(want) {
+			t.Fatalf("unexpected image size %dx%d, want %d pixels", image.width, image.height, len(want))
+		}
+		for cIdx, cPixel := range pixels {
+			if cPixel != (want[cIdx] == '1') {
+				t.Fatalf("unexpected data result, want '%s', got '%v'", want, pixels)
+			}
+		}
+	}
+	if _, err := decoder.Next(); err != io.EOF {
+		t.Fatalf("expected end of stream, got %v", err)
+	}
+}
+
+func TestDecoder_mixed(t *testing.T) {
+	input := "P2 1 1 255 10\nP6 1 1 255 \x01\x02\x03P4 1 1 \x80"
+	decoder := NewDecoder(strings.NewReader(input))
+	for _, want := range []string{"*pbm.GrayImage", "*pbm.ColorImage", "*pbm.Image"} {
+		image, err := decoder.NextNetpbm()
+		if err != nil {
+			t.Fatalf("unexpected decode error: %s", err)
+		}
+		if got := fmt.Sprintf("%T", image); got != want {
+			t.Fatalf("unexpected type, want %s, got %s", want, got)
+		}
+	}
+	if _, err := decoder.NextNetpbm(); err != io.EOF {
+		t.Fatalf("expected end of stream, got %v", err)
+	}
+}
+
+func TestDecoder_invalid(t *testing.T) {
+	inputs := []string{
+		"",
+		"P1 1 1 1 P2 1 1 1 1",
+		"P1 1 1 1 00",
+		"P1 1 1 1 P",
+		"P4 1 1 \x80\x80",
+	}
+	for _, input := range inputs {
+		decoder := NewDecoder(strings.NewReader(input))
+		var err error
+		for err == nil {
+			_, err = decoder.Next()
+		}
+		if err == io.EOF {
+			t.Fatalf("should have fail: invalid stream '%s'", input)
+		}
+	}
+}
+
+func TestEncoder_multiple(t *testing.T) {
+	input := "P1\n2 1\n10\nP1\n1 2\n0\n1\n"
+	decoder := NewDecoder(strings.NewReader(input))
+	output := bytes.Buffer{}
+	encoder := NewEncoder(&output, FormatASCII)
+	for {
+		image, err := decoder.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("unexpected decode error: %s", err)
+		}
+		if err := encoder.Encode(image); err != nil {
+			t.Fatalf("unexpected encode error: %s", err)
+		}
+	}
+	if output.String() != input {
+		t.Fatalf("unexpected output: %s", output.String())
+	}
+}
+
+func TestEncoder_formats(t *testing.T) {
+	image := newImageFromPixels(1, 1, []bool{true})
+	expects := map[Format]string{
+		FormatASCII:  "P1\n1 1\n1\n",
+		FormatBinary: "P4\n1 1\n\x80",
+		FormatPAM:    "P7\nWIDTH 1\nHEIGHT 1\nDEPTH 1\nMAXVAL 1\nTUPLTYPE BLACKANDWHITE\nENDHDR\n\x00",
+	}
+	for format, expect := range expects {
+		output := bytes.Buffer{}
+		if err := NewEncoder(&output, format).Encode(image); err != nil {
+			t.Fatalf("unexpected encode error: %s", err)
+		}
+		if output.String() != expect {
+			t.Fatalf("unexpected output: %v, want %v", output.Bytes(), []byte(expect))
+		}
+	}
+	if err := NewEncoder(io.Discard, Format(42)).Encode(image); err == nil {
+		t.Fatalf("should have fail: unknown format")
+	}
+}
+
+func TestDecoder_endEmptyToken(t *testing.T) {
+	// trailing separators give an empty token, as with scanners before go 1.22
+	// on final token error without token, then given trailing data
+	for _, cData := range []string{"", "00"} {
+		data := cData
+		scanner := bufio.NewScanner(strings.NewReader(" \n"))
+		scanner.Split(func(input []byte, atEOF bool) (int, []byte, error) {
+			switch {
+			case !atEOF:
+				return 0, nil, nil
+			case len(input) != 0:
+				return len(input), []byte{}, nil
+			case data != "":
+				token := []byte(data)
+				data = ""
+				return 0, token, nil
+			}
+			return 0, nil, nil
+		})
+		decoder := &Decoder{scanner: scanner, tokenizer: &splitter{}}
+		if err := decoder.end(); (err != nil) != (cData != "") {
+			t.Fatalf("unexpected end result '%v' with trailing data '%s'", err, cData)
+		}
+	}
+}
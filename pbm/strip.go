@@ -0,0 +1,370 @@
+// Copyright 2023 Xavier MARCELET. All rights reserved.
+// Use of this source code is governed by Apache
+// license that can be found in the LICENSE file.
+
+package pbm
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"io"
+	"math"
+)
+
+// stripMargin - extra source pixels read around the area a tile needs,
+// covers rounding of mapped coordinates
+const stripMargin = 2
+
+// Strips - lazy transformation of a raw (P4) bitmap file, processed by
+// horizontal strips so that neither source nor result image is ever held
+// whole in memory
+//
+// Operations are recorded until Encode renders result strip by strip. Each
+// strip is split in tiles, for which only source rows and columns the tile
+// needs are read from file.
+type Strips struct {
+	source   io.ReaderAt
+	offset   int64 // position of data section in source
+	width    int   // source image width
+	height   int   // source image height
+	result   size  // dimensions of result of recorded operations
+	workers  int   // number of concurrent workers, GOMAXPROCS when zero
+	inverses []func(x, y int) (int, int)
+}
+
+// NewStrips prepares processing of raw bitmap file read from given source
+func NewStrips(source io.ReaderAt) (*Strips, error) {
+	dimensions, offset, err := rawHeader(source)
+	if err != nil {
+		return nil, err
+	}
+	return &Strips{
+		source: source,
+		offset: offset,
+		width:  dimensions.width,
+		height: dimensions.height,
+		result: dimensions,
+	}, nil
+}
+
+// rawHeader reads header of raw bitmap, returns image dimensions and
+// position of data section
+//
+// Header is read byte per byte so that position of data section is known, it
+// follows the single whitespace after height.
+//
+//  1. separators and comments before value
+//  2. whitespace after value is consumed
+func rawHeader(source io.ReaderAt) (size, int64, error) {
+	var (
+		reader = bufio.NewReader(io.NewSectionReader(source, 0, math.MaxInt64))
+		offset = int64(2)
+		values = [2]int{}
+		names  = [2]string{"width", "height"}
+	)
+	magic := make([]byte, 2)
+	if _, err := io.ReadFull(reader, magic); err != nil || string(magic) != PBMMagicP4 {
+		return size{}, 0, fmt.Errorf("invalid magic number '%s', expecting %s", magic, PBMMagicP4)
+	}
+	next := func() (byte, error) {
+		offset++
+		return reader.ReadByte()
+	}
+
+	for cIdx := range values {
+		char, err := next()
+		// 1.
+		for err == nil && (char == ' ' || char == '\t' || char == '\r' || char == '\n' || char == '#') {
+			for err == nil && char == '#' {
+				char, err = next()
+				for err == nil && char != '\n' {
+					char, err = next()
+				}
+			}
+			char, err = next()
+		}
+		digits := 0
+		for err == nil && char >= '0' && char <= '9' && values[cIdx] < math.MaxInt32 {
+			values[cIdx] = values[cIdx]*10 + int(char-'0')
+			digits++
+			char, err = next()
+		}
+		// 2.
+		if err != nil || digits == 0 || values[cIdx] > math.MaxInt32 || (char != ' ' && char != '\t' && char != '\r' && char != '\n') {
+			return size{}, 0, fmt.Errorf("invalid %s, expecting number followed by whitespace", names[cIdx])
+		}
+	}
+	return size{values[0], values[1]}, offset, nil
+}
+
+// Width returns width of result image
+func (s *Strips) Width() int {
+	return s.result.width
+}
+
+// Height returns height of result image
+func (s *Strips) Height() int {
+	return s.result.height
+}
+
+// Orient records given orientation, fails for values out of 1 to 8
+func (s *Strips) Orient(orientation Orientation) error {
+	if err := orientation.check(); err != nil {
+		return err
+	}
+	if orientation.swapsSize() {
+		s.result = size{s.result.height, s.result.width}
+	}
+	oriented, inverse := s.result, orientation.inverse()
+	s.inverses = append(s.inverses, func(x, y int) (int, int) {
+		return orientPoint(x, y, oriented.width, oriented.height, inverse)
+	})
+	return nil
+}
+
+// Rotate records rotation of given angle, see Image.Rotate
+//
+// Forward mapping is not supported. Black pixels are not known beforehand,
+// WithCentroid falls back to geometric center.
+func (s *Strips) Rotate(angle float64, opts ...Option) error {
+	rotation := newRotation(angle, s, opts)
+	if rotation.forward {
+		return fmt.Errorf("invalid option, forward mapping is not supported by strips")
+	}
+	s.workers = rotation.workers
+	if rotation.turns != 0 {
+		_ = s.Orient(turnOrientations[rotation.turns])
+	}
+	if rotation.mapper == nil {
+		return nil
+	}
+	bounds, rotator := rotation.bounds, rotation.mapper
+	s.inverses = append(s.inverses, func(x, y int) (int, int) {
+		return rotator.Inverse(x+bounds.Min.X, y+bounds.Min.Y)
+	})
+	s.result = size{bounds.Dx(), bounds.Dy()}
+	return nil
+}
+
+// sourcePoint gives coordinates of source pixel of given result pixel,
+// through recorded operations from last to first
+func (s *Strips) sourcePoint(x, y int) (int, int) {
+	for cIdx := len(s.inverses) - 1; cIdx >= 0; cIdx-- {
+		x, y = s.inverses[cIdx](x, y)
+	}
+	return x, y
+}
+
+// windowMapper - maps result pixels to pixels of a source window
+type windowMapper struct {
+	strips *Strips
+	origin image.Point // position of window in source image
+}
+
+func (w windowMapper) Inverse(x, y int) (int, int) {
+	x, y = w.strips.sourcePoint(x, y)
+	return x - w.origin.X, y - w.origin.Y
+}
+
+// area gives source pixels needed by given result tile, not restricted to
+// source bounds
+//
+// Recorded operations map edges of tile to edges of its source area, other
+// pixels fall in between.
+func (s *Strips) area(tile image.Rectangle) image.Rectangle {
+	var (
+		minX, minY = math.MaxInt, math.MaxInt
+		maxX, maxY = math.MinInt, math.MinInt
+	)
+	visit := func(x, y int) {
+		x, y = s.sourcePoint(x, y)
+		if x < minX {
+			minX = x
+		}
+		if x > maxX {
+			maxX = x
+		}
+		if y < minY {
+			minY = y
+		}
+		if y > maxY {
+			maxY = y
+		}
+	}
+	for x := tile.Min.X; x < tile.Max.X; x++ {
+		visit(x, tile.Min.Y)
+		visit(x, tile.Max.Y-1)
+	}
+	for y := tile.Min.Y; y < tile.Max.Y; y++ {
+		visit(tile.Min.X, y)
+		visit(tile.Max.X-1, y)
+	}
+	if tile.Empty() {
+		return image.Rectangle{}
+	}
+	return image.Rect(minX-stripMargin, minY-stripMargin, maxX+1+stripMargin, maxY+1+stripMargin)
+}
+
+// window gives source area to read for given result tile, restricted to
+// source bounds and aligned on words
+func (s *Strips) window(tile image.Rectangle) image.Rectangle {
+	area := s.area(tile).Intersect(image.Rect(0, 0, s.width, s.height))
+	if area.Empty() {
+		return image.Rectangle{}
+	}
+	area.Min.X -= area.Min.X % wordBits
+	area.Max.X = words(area.Max.X) * wordBits
+	if area.Max.X > s.width {
+		area.Max.X = s.width
+	}
+	return area
+}
+
+// read loads given area of source image into given window, rows are read one
+// by one through given buffer
+//
+//  1. buffers are sized for largest window by windowSize, they only grow
+//     when rounding of mapped coordinates exceeds it
+//  2. area starts on a word, bytes of source row are copied as is to words,
+//     pixels past area are cleared
+func (s *Strips) read(area image.Rectangle, window *Image, buffer []byte) error {
+	window.width, window.height, window.stride = area.Dx(), area.Dy(), words(area.Dx())
+	rowBytes, length := (s.width+7)/8, (area.Dx()+7)/8
+	// 1.
+	if cap(window.data) < window.stride*window.height {
+		window.data = make([]uint64, window.stride*window.height)
+	}
+	if len(buffer) < length {
+		buffer = make([]byte, length)
+	}
+	window.data, buffer = window.data[:window.stride*window.height], buffer[:length]
+	for y := 0; y < window.height; y++ {
+		position := s.offset + int64(area.Min.Y+y)*int64(rowBytes) + int64(area.Min.X/8)
+		if count, err := s.source.ReadAt(buffer, position); count != len(buffer) {
+			return fmt.Errorf("invalid data, could not read row %d: %s", area.Min.Y+y, err)
+		}
+		// 2.
+		row := window.row(y)
+		for cIdx := range row {
+			row[cIdx] = 0
+		}
+		for cIdx, cByte := range buffer {
+			row[cIdx/8] |= uint64(cByte) << (56 - 8*(cIdx%8))
+		}
+		if window.stride != 0 {
+			row[window.stride-1] &= window.tail()
+		}
+	}
+	return nil
+}
+
+// windowSize gives largest number of words per row and of rows of source
+// windows read for tiles of given dimensions
+//
+// Size of source area of a tile doesn't depend on its position, recorded
+// operations move neighbour tiles to neighbour areas.
+//
+//  1. one more pixel covers rounding of mapped coordinates, alignment on
+//     words adds up to one word
+func (s *Strips) windowSize(tileWidth, stripHeight int) (int, int) {
+	area := s.area(image.Rect(0, 0, tileWidth, stripHeight))
+	// 1.
+	stride, height := words(area.Dx()+1)+1, area.Dy()+1
+	if stride > words(s.width) {
+		stride = words(s.width)
+	}
+	if height > s.height {
+		height = s.height
+	}
+	return stride, height
+}
+
+// cost gives memory needed to render strips of given height split in tiles
+// of given width: strip, source window, read buffer of a window row and
+// encoded row of strip
+func (s *Strips) cost(tileWidth, stripHeight int) int {
+	stride, height := s.windowSize(tileWidth, stripHeight)
+	return 8*(words(s.result.width)*stripHeight+stride*height+stride) + (s.result.width+7)/8
+}
+
+// layout chooses height of strips and width of tiles so that rendering fits
+// in given memory budget, in bytes
+//
+// Largest of both is halved until cost fits budget, tiles width remains a
+// multiple of 64 so that tiles are rendered to strips word by word.
+func (s *Strips) layout(budget int) (int, int, error) {
+	tileWidth, stripHeight := words(s.result.width)*wordBits, s.result.height
+	if tileWidth == 0 {
+		tileWidth = wordBits
+	}
+	if stripHeight == 0 {
+		stripHeight = 1
+	}
+	for {
+		cost := s.cost(tileWidth, stripHeight)
+		switch {
+		case cost <= budget:
+			return tileWidth, stripHeight, nil
+		case tileWidth > wordBits && (tileWidth >= stripHeight || stripHeight == 1):
+			tileWidth = words(tileWidth/2) * wordBits
+		case stripHeight > 1:
+			stripHeight = (stripHeight + 1) / 2
+		default:
+			return 0, 0, fmt.Errorf("invalid memory budget '%d', expecting at least %d bytes", budget, cost)
+		}
+	}
+}
+
+// Encode renders result of recorded operations to stream in raw
+// representation, using no more than given memory budget, in bytes
+//
+// Strips are written row by row as soon as all their tiles are rendered.
+// Tiles are rendered in place into strip by inverse mapping with configured
+// number of workers. Buffers are allocated once, see cost.
+//
+//  1. strip buffer is reused, every row of last strip is overwritten by tiles
+func (s *Strips) Encode(stream io.Writer, budget int) error {
+	tileWidth, stripHeight, err := s.layout(budget)
+	if err != nil {
+		return err
+	}
+	if err := encodeHeader(stream, PBMMagicP4, s); err != nil {
+		return err
+	}
+
+	var (
+		stride, height = s.windowSize(tileWidth, stripHeight)
+		strip          = newImage(s.result.width, stripHeight)
+		window         = &Image{data: make([]uint64, stride*height)}
+		buffer         = make([]byte, 8*stride)
+		line           = make([]byte, (s.result.width+7)/8)
+		mapper         = &windowMapper{strips: s}
+		renderer       = &inverseRenderer{dst: strip, src: window, rotator: mapper}
+		render         = renderer.rows
+	)
+	for top := 0; top < s.result.height; top += stripHeight {
+		// 1.
+		if top+stripHeight > s.result.height {
+			strip.height = s.result.height - top
+			strip.data = strip.data[:strip.height*strip.stride]
+		}
+		for left := 0; left < s.result.width; left += tileWidth {
+			tile := image.Rect(left, top, left+tileWidth, top+strip.height).Intersect(image.Rect(0, 0, s.result.width, s.result.height))
+			area := s.window(tile)
+			if err := s.read(area, window, buffer); err != nil {
+				return err
+			}
+			mapper.origin = area.Min
+			renderer.column, renderer.bounds = left/wordBits, tile
+			parallelRows(tile.Dy(), s.workers, render)
+		}
+		for y := 0; y < strip.height; y++ {
+			packRow(line, strip.row(y))
+			if _, err := stream.Write(line); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
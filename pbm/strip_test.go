@@ -0,0 +1,172 @@
+// Copyright 2023 Xavier MARCELET. All rights reserved.
+// Use of this source code is governed by Apache
+// license that can be found in the LICENSE file.
+
+package pbm
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// budgets - memory budgets from whole image down to a few rows per strip
+var budgets = []int{1 << 20, 4000, 1500}
+
+func newStrips(t *testing.T, width, height int, pixels []bool) *Strips {
+	t.Helper()
+
+	writer := bytes.Buffer{}
+	if err := newImageFromPixels(width, height, pixels).EncodeBinary(&writer); err != nil {
+		t.Fatalf("unexpected encode error: %s", err)
+	}
+	strips, err := NewStrips(bytes.NewReader(writer.Bytes()))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	return strips
+}
+
+func checkStrips(t *testing.T, strips *Strips, budget int, expected *Image) {
+	t.Helper()
+
+	result, want := bytes.Buffer{}, bytes.Buffer{}
+	if err := strips.Encode(&result, budget); err != nil {
+		t.Fatalf("unexpected error with budget %d: %s", budget, err)
+	}
+	if err := expected.EncodeBinary(&want); err != nil {
+		t.Fatalf("unexpected encode error: %s", err)
+	}
+	if result.String() != want.String() {
+		t.Fatalf("unexpected output with budget %d", budget)
+	}
+}
+
+func TestStrips_orient(t *testing.T) {
+	pixels := randomPixels(150, 90)
+	for orientation := OrientationNormal; orientation <= OrientationRotate270; orientation++ {
+		for _, cBudget := range budgets {
+			strips := newStrips(t, 150, 90, pixels)
+			if err := strips.Orient(orientation); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			expected := newImageFromPixels(150, 90, pixels)
+			_ = expected.Orient(orientation)
+			checkStrips(t, strips, cBudget, expected)
+		}
+	}
+}
+
+func TestStrips_rotate(t *testing.T) {
+	pixels := randomPixels(150, 90)
+	for _, cAngle := range []float64{90, 180, 270, 12.5, -30, 135} {
+		for _, cAlgorithm := range []Algorithm{AlgorithmRotator, AlgorithmShear} {
+			for _, cBudget := range budgets {
+				opts := []Option{WithAlgorithm(cAlgorithm), WithResize()}
+				strips := newStrips(t, 150, 90, pixels)
+				if err := strips.Rotate(cAngle, opts...); err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				expected := newImageFromPixels(150, 90, pixels)
+				expected.Rotate(cAngle, opts...)
+				checkStrips(t, strips, cBudget, expected)
+			}
+		}
+	}
+}
+
+func TestStrips_chain(t *testing.T) {
+	pixels := randomPixels(70, 130)
+	for _, cBudget := range budgets {
+		strips := newStrips(t, 70, 130, pixels)
+		_ = strips.Orient(OrientationTransverse)
+		_ = strips.Rotate(-20, WithResize())
+		_ = strips.Orient(OrientationFlipHorizontal)
+
+		expected := newImageFromPixels(70, 130, pixels)
+		_ = expected.Orient(OrientationTransverse)
+		expected.Rotate(-20, WithResize())
+		_ = expected.Orient(OrientationFlipHorizontal)
+		checkStrips(t, strips, cBudget, expected)
+	}
+}
+
+func TestStrips_header(t *testing.T) {
+	strips, err := NewStrips(strings.NewReader("P4 # comment\n3\t# other\n\n2\n\xff\xbf"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	_ = strips.Orient(OrientationRotate180)
+	expected, _ := NewImageFromString("P4\n3 2\n\xff\xbf")
+	_ = expected.Orient(OrientationRotate180)
+	checkStrips(t, strips, 1000, expected)
+
+	for _, cInput := range []string{"P1\n3 2\n", "P4\n3", "P4\n3 x\n", "P4 -3 2\n"} {
+		if _, err := NewStrips(strings.NewReader(cInput)); err == nil {
+			t.Fatalf("expected error for header %q", cInput)
+		}
+	}
+}
+
+func TestStrips_errors(t *testing.T) {
+	pixels := randomPixels(150, 90)
+	strips := newStrips(t, 150, 90, pixels)
+	if err := strips.Rotate(30, WithForwardMapping()); err == nil {
+		t.Fatalf("expected error for forward mapping")
+	}
+	if err := strips.Orient(Orientation(9)); err == nil {
+		t.Fatalf("expected error for invalid orientation")
+	}
+	if err := strips.Encode(&bytes.Buffer{}, 100); err == nil {
+		t.Fatalf("expected error for too small budget")
+	}
+
+	truncated, err := NewStrips(strings.NewReader("P4\n3 2\n\xff"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := truncated.Encode(&bytes.Buffer{}, 1000); err == nil {
+		t.Fatalf("expected error for truncated data")
+	}
+}
+
+// TestStrips_budget checks memory allocated while encoding, which bounds
+// peak usage, against budget
+//
+// Allocator rounds large buffers up to pages, a page per buffer is tolerated.
+func TestStrips_budget(t *testing.T) {
+	var (
+		width, height = 2000, 1200
+		random        = rand.New(rand.NewSource(1))
+		data          = make([]byte, (width+7)/8*height)
+		rounding      = uint64(4 * 8192)
+	)
+	random.Read(data)
+	input := append([]byte(fmt.Sprintf("P4\n%d %d\n", width, height)), data...)
+	for _, cAngle := range []float64{0, 90, 10, -135} {
+		for _, cAlgorithm := range []Algorithm{AlgorithmRotator, AlgorithmShear} {
+			for _, cBudget := range []int{256 << 10, 64 << 10, 16 << 10} {
+				strips, err := NewStrips(bytes.NewReader(input))
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				if err := strips.Rotate(cAngle, WithAlgorithm(cAlgorithm), WithResize(), WithWorkers(1)); err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				before, after := runtime.MemStats{}, runtime.MemStats{}
+				runtime.ReadMemStats(&before)
+				if err := strips.Encode(io.Discard, cBudget); err != nil {
+					t.Fatalf("unexpected error with budget %d: %s", cBudget, err)
+				}
+				runtime.ReadMemStats(&after)
+				if allocated := after.TotalAlloc - before.TotalAlloc; allocated > uint64(cBudget)+rounding {
+					t.Fatalf("unexpected allocation of %d bytes for angle %v with budget %d", allocated, cAngle, cBudget)
+				}
+			}
+		}
+	}
+}
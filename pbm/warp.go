@@ -0,0 +1,272 @@
+// Copyright 2023 Xavier MARCELET. All rights reserved.
+// Use of this source code is governed by Apache
+// license that can be found in the LICENSE file.
+
+package pbm
+
+import (
+	"fmt"
+	"image"
+	"math"
+)
+
+// Homography - 3x3 matrix of a projective transformation, row by row
+//
+// Point (x,y) is mapped to ((h0*x + h1*y + h2) / w, (h3*x + h4*y + h5) / w)
+// where w = h6*x + h7*y + h8.
+type Homography [9]float64
+
+// NewHomography computes projective transformation mapping each of from
+// points to the matching to point, fails when three points are aligned
+//
+// With h8 = 1, each pair of points gives two linear equations of remaining
+// coefficients:
+//
+//	h0*x + h1*y + h2 - h6*x*x' - h7*y*x' = x'
+//	h3*x + h4*y + h5 - h6*x*y' - h7*y*y' = y'
+//
+// Matrix is then negated, which is the same transformation, when w is
+// negative on given points so that they are in front of the viewer, see
+// Apply. Sign of w at the origin doesn't matter: it may lie beyond the
+// vanishing line.
+func NewHomography(from, to [4]image.Point) (Homography, error) {
+	var system [8][9]float64
+	for cIdx := range from {
+		x, y := float64(from[cIdx].X), float64(from[cIdx].Y)
+		u, v := float64(to[cIdx].X), float64(to[cIdx].Y)
+		system[cIdx*2] = [9]float64{x, y, 1, 0, 0, 0, -x * u, -y * u, u}
+		system[cIdx*2+1] = [9]float64{0, 0, 0, x, y, 1, -x * v, -y * v, v}
+	}
+
+	solution, err := solve(system)
+	if err != nil {
+		return Homography{}, fmt.Errorf("invalid corners, three points must not be aligned")
+	}
+
+	var result Homography
+	copy(result[:], solution[:])
+	result[8] = 1
+	if result[6]*float64(from[0].X)+result[7]*float64(from[0].Y)+result[8] < 0 {
+		for cIdx := range result {
+			result[cIdx] = -result[cIdx]
+		}
+	}
+	return result, nil
+}
+
+// solve computes solution of given augmented linear system using gaussian
+// elimination with partial pivoting
+//
+//  1. swap current row with the one holding largest pivot
+//  2. eliminate current variable from following rows
+//  3. back substitution
+func solve(system [8][9]float64) ([8]float64, error) {
+	var solution [8]float64
+	const size = len(solution)
+
+	for col := 0; col < size; col++ {
+		// 1.
+		pivot := col
+		for row := col + 1; row < size; row++ {
+			if math.Abs(system[row][col]) > math.Abs(system[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(system[pivot][col]) < 1e-12 {
+			return solution, fmt.Errorf("singular system")
+		}
+		system[col], system[pivot] = system[pivot], system[col]
+
+		// 2.
+		for row := col + 1; row < size; row++ {
+			factor := system[row][col] / system[col][col]
+			for cIdx := col; cIdx <= size; cIdx++ {
+				system[row][cIdx] -= factor * system[col][cIdx]
+			}
+		}
+	}
+
+	// 3.
+	for row := size - 1; row >= 0; row-- {
+		value := system[row][size]
+		for col := row + 1; col < size; col++ {
+			value -= system[row][col] * solution[col]
+		}
+		solution[row] = value / system[row][row]
+	}
+	return solution, nil
+}
+
+// Apply computes coordinates of transformed point, last value is false when
+// point is projected to infinity or behind the viewer
+func (h Homography) Apply(x float64, y float64) (float64, float64, bool) {
+	w := h[6]*x + h[7]*y + h[8]
+	if w <= 0 {
+		return 0, 0, false
+	}
+	return (h[0]*x + h[1]*y + h[2]) / w, (h[3]*x + h[4]*y + h[5]) / w, true
+}
+
+// Invert returns the inverse transformation, fails when matrix is singular
+//
+// Inverse is the adjugate matrix divided by determinant, the exact inverse:
+// homogeneous coordinates of points in front of the viewer keep a positive w
+// through it.
+func (h Homography) Invert() (Homography, error) {
+	adjugate := Homography{
+		h[4]*h[8] - h[5]*h[7], h[2]*h[7] - h[1]*h[8], h[1]*h[5] - h[2]*h[4],
+		h[5]*h[6] - h[3]*h[8], h[0]*h[8] - h[2]*h[6], h[2]*h[3] - h[0]*h[5],
+		h[3]*h[7] - h[4]*h[6], h[1]*h[6] - h[0]*h[7], h[0]*h[4] - h[1]*h[3],
+	}
+	det := h[0]*adjugate[0] + h[1]*adjugate[3] + h[2]*adjugate[6]
+	if det == 0 {
+		return Homography{}, fmt.Errorf("invalid transformation, matrix is not invertible")
+	}
+	for cIdx := range adjugate {
+		adjugate[cIdx] /= det
+	}
+	return adjugate, nil
+}
+
+// Warper computes projected coordinates for a given (x,y) point
+// Stores constant values for a given projection of a given image
+type Warper struct {
+	width   int        // width of source image
+	height  int        // height of source image
+	matrix  Homography // projection of source pixels
+	inverse Homography // inverse of matrix
+}
+
+// outside - coordinates of pixels that can't be projected, far outside any image
+const outside = math.MinInt32
+
+// NewWarper initializes warper object for a projection mapping from points of
+// image to given to points
+func NewWarper(from, to [4]image.Point, img Sizer) (*Warper, error) {
+	matrix, err := NewHomography(from, to)
+	if err != nil {
+		return nil, err
+	}
+	inverse, err := matrix.Invert()
+	if err != nil {
+		return nil, err
+	}
+	return &Warper{
+		width:   img.Width(),
+		height:  img.Height(),
+		matrix:  matrix,
+		inverse: inverse,
+	}, nil
+}
+
+// project applies given matrix to pixel coordinates
+func project(h Homography, x int, y int) (int, int) {
+	x1, y1, ok := h.Apply(float64(x), float64(y))
+	if !ok || math.Abs(x1) > math.MaxInt32 || math.Abs(y1) > math.MaxInt32 {
+		return outside, outside
+	}
+	return int(math.Round(x1)), int(math.Round(y1))
+}
+
+// Compute computes pixel new coordinates after projection
+func (w *Warper) Compute(x int, y int) (int, int) {
+	return project(w.matrix, x, y)
+}
+
+// Inverse computes source pixel coordinates of a pixel after projection
+func (w *Warper) Inverse(x int, y int) (int, int) {
+	return project(w.inverse, x, y)
+}
+
+// Bounds computes the smallest rectangle holding all projected corners
+//
+// Projection preserves straight lines, image stays within its projected
+// corners as long as none of them is sent to infinity.
+func (w *Warper) Bounds() image.Rectangle {
+	var bounds image.Rectangle
+	if w.width == 0 || w.height == 0 {
+		return bounds
+	}
+	corners := [][2]int{{0, 0}, {w.width - 1, 0}, {0, w.height - 1}, {w.width - 1, w.height - 1}}
+	for cIdx, cCorner := range corners {
+		x, y := w.Compute(cCorner[0], cCorner[1])
+		if x == outside {
+			continue
+		}
+		corner := image.Rect(x, y, x+1, y+1)
+		if cIdx == 0 || bounds.Empty() {
+			bounds = corner
+		}
+		bounds = bounds.Union(corner)
+	}
+	return bounds
+}
+
+// newWarp prepares projection of given image according to options, fails
+// when corners don't define a projection
+func newWarp(from, to [4]image.Point, img Sizer, opts []Option) (*transformation, error) {
+	settings := newOptions(opts)
+	warper, err := NewWarper(from, to, img)
+	if err != nil {
+		return nil, err
+	}
+	result := &transformation{
+		mapper:  warper,
+		bounds:  image.Rect(0, 0, img.Width(), img.Height()),
+		forward: settings.forward,
+		workers: settings.workers,
+	}
+	if settings.resize {
+		result.bounds = warper.Bounds()
+	}
+	return result, nil
+}
+
+// Warp applies perspective projection mapping from points to given to points
+//
+// Typical use straightens a photographed document: from are corners of the
+// paper in photo and to are corners of image. Pixels projected outside image
+// boundaries will be lost unless WithResize option is given, uncovered pixels
+// are white.
+func (i *Image) Warp(from, to [4]image.Point, opts ...Option) error {
+	warp, err := newWarp(from, to, i, opts)
+	if err != nil {
+		return err
+	}
+	*i = *transformBits(i, warp)
+	return nil
+}
+
+// Warp applies perspective projection mapping from points to given to points
+//
+// See Image.Warp, uncovered pixels are white, or transparent when image has an
+// alpha channel.
+func (g *GrayImage) Warp(from, to [4]image.Point, opts ...Option) error {
+	warp, err := newWarp(from, to, g, opts)
+	if err != nil {
+		return err
+	}
+	g.data = apply(warp, g.data, g.width, g.height, uint16(g.maxval))
+	if g.HasAlpha() {
+		g.alpha = apply(warp, g.alpha, g.width, g.height, 0)
+	}
+	g.width, g.height = warp.bounds.Dx(), warp.bounds.Dy()
+	return nil
+}
+
+// Warp applies perspective projection mapping from points to given to points
+//
+// See Image.Warp, uncovered pixels are white, or transparent when image has an
+// alpha channel.
+func (c *ColorImage) Warp(from, to [4]image.Point, opts ...Option) error {
+	warp, err := newWarp(from, to, c, opts)
+	if err != nil {
+		return err
+	}
+	c.data = apply(warp, c.data, c.width, c.height, c.white())
+	if c.HasAlpha() {
+		c.alpha = apply(warp, c.alpha, c.width, c.height, 0)
+	}
+	c.width, c.height = warp.bounds.Dx(), warp.bounds.Dy()
+	return nil
+}
@@ -0,0 +1,116 @@
+// Copyright 2023 Xavier MARCELET. All rights reserved.
+// Use of this source code is governed by Apache
+// license that can be found in the LICENSE file.
+
+package pbm
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+// warp gives operation warping given corners to given positions
+func warp(from, to [4]image.Point, opts ...Option) func(*Image) error {
+	return func(img *Image) error { return img.Warp(from, to, opts...) }
+}
+
+func TestHomography(t *testing.T) {
+	from := [4]image.Point{{2, 0}, {4, 0}, {6, 4}, {0, 4}}
+	to := [4]image.Point{{0, 0}, {6, 0}, {6, 4}, {0, 4}}
+	matrix, err := NewHomography(from, to)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	inverse, err := matrix.Invert()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for cIdx := range from {
+		x, y, ok := matrix.Apply(float64(from[cIdx].X), float64(from[cIdx].Y))
+		if !ok || math.Abs(x-float64(to[cIdx].X)) > 1e-9 || math.Abs(y-float64(to[cIdx].Y)) > 1e-9 {
+			t.Fatalf("point %d projected to (%f,%f)", cIdx, x, y)
+		}
+		x, y, ok = inverse.Apply(x, y)
+		if !ok || math.Abs(x-float64(from[cIdx].X)) > 1e-9 || math.Abs(y-float64(from[cIdx].Y)) > 1e-9 {
+			t.Fatalf("point %d projected back to (%f,%f)", cIdx, x, y)
+		}
+	}
+
+	aligned := [4]image.Point{{0, 0}, {1, 1}, {2, 2}, {0, 4}}
+	if _, err := NewHomography(aligned, to); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestHomography_vanishingLine(t *testing.T) {
+	// origin lies beyond vanishing line of transformation, w is negative there
+	from := [4]image.Point{{200, 0}, {300, 0}, {300, 200}, {200, 100}}
+	to := [4]image.Point{{150, 0}, {200, 0}, {200, 100}, {150, 100}}
+	matrix, err := NewHomography(from, to)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for cIdx := range from {
+		if _, _, ok := matrix.Apply(float64(from[cIdx].X), float64(from[cIdx].Y)); !ok {
+			t.Fatalf("point %d projected behind the viewer", cIdx)
+		}
+	}
+
+	pixels := make([]bool, 400*300)
+	for cIdx := range pixels {
+		pixels[cIdx] = true
+	}
+	img := newImageFromPixels(400, 300, pixels)
+	if err := img.Warp(from, to); err != nil {
+		t.Fatalf("unexpected warp error: %s", err)
+	}
+	if count := img.count(); count < 50*100 {
+		t.Fatalf("unexpected %d black pixels, target area is blank", count)
+	}
+}
+
+func TestWarp_identity(t *testing.T) {
+	in := `P1
+3 2
+100
+011
+`
+	corners := [4]image.Point{{0, 0}, {2, 0}, {2, 1}, {0, 1}}
+	checkOperation(t, in, in, warp(corners, corners))
+}
+
+func TestWarp_trapezoid(t *testing.T) {
+	// photographed page is stretched to the whole frame
+	in := `P1
+7 5
+0011100
+0111110
+0111110
+1111111
+1111111
+`
+	out := `P1
+7 5
+1111111
+1111111
+1111111
+1111111
+1111111
+`
+	from := [4]image.Point{{2, 0}, {4, 0}, {6, 4}, {0, 4}}
+	to := [4]image.Point{{0, 0}, {6, 0}, {6, 4}, {0, 4}}
+	checkOperation(t, in, out, warp(from, to))
+
+	// frame is projected to trapezoid, farther rows are compressed
+	in, out = out, `P1
+7 5
+0011100
+0011100
+0111110
+0111110
+1111111
+`
+	checkOperation(t, in, out, warp(to, from))
+}